@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/client"
+	"github.com/IPampurin/calendar-server/pkg/ical"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	searchSpan  = 365 * 24 * time.Hour // диапазон search/export по умолчанию - год назад и год вперёд
+	formatTable = "table"
+	formatJSON  = "json"
+	formatICS   = "ics"
+)
+
+// newFlagSet создаёт набор флагов подкоманды с обязательным -user
+func newFlagSet(name string) (*flag.FlagSet, *int) {
+
+	fs := flag.NewFlagSet("calctl "+name, flag.ContinueOnError)
+	userID := fs.Int("user", 0, "ID пользователя")
+
+	return fs, userID
+}
+
+// parseFlags разбирает флаги и проверяет -user
+func parseFlags(fs *flag.FlagSet, userID *int, args []string) error {
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *userID <= 0 {
+		return fmt.Errorf("%s: укажите -user (положительное число)", fs.Name())
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: лишние аргументы %v", fs.Name(), fs.Args())
+	}
+
+	return nil
+}
+
+// parseDate разбирает дату YYYY-MM-DD (пустая строка - значение по умолчанию)
+func parseDate(value string, fallback time.Time) (time.Time, error) {
+
+	if value == "" {
+		return fallback, nil
+	}
+
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверная дата %q (используйте YYYY-MM-DD)", value)
+	}
+
+	return date, nil
+}
+
+// today возвращает сегодняшнюю дату без времени
+func today() time.Time {
+
+	now := time.Now()
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseRange разбирает -from и -to (по умолчанию - span в обе стороны от сегодня)
+func parseRange(from, to string, span time.Duration) (time.Time, time.Time, error) {
+
+	start, err := parseDate(from, today().Add(-span))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parseDate(to, today().Add(span))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-to раньше -from")
+	}
+
+	return start, end, nil
+}
+
+// fetchRange собирает события за диапазон дат включительно (по месяцам - других выборок у API нет)
+func fetchRange(ctx context.Context, c *client.Client, userID int, from, to time.Time) ([]*client.Event, error) {
+
+	seen := make(map[int]bool)
+	events := make([]*client.Event, 0)
+
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		monthEvents, err := c.EventsForMonth(ctx, userID, month)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range monthEvents {
+			day := time.Date(event.Date.Year(), event.Date.Month(), event.Date.Day(), 0, 0, 0, 0, time.UTC)
+			if seen[event.ID] || day.Before(from) || day.After(to) {
+				continue
+			}
+			seen[event.ID] = true
+			events = append(events, event)
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+
+	sortEvents(events)
+
+	return events, nil
+}
+
+// sortEvents сортирует события по дате, затем по ID
+func sortEvents(events []*client.Event) {
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Date.Equal(events[j].Date) {
+			return events[i].Date.Before(events[j].Date)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+// cmdAdd создаёт событие
+func cmdAdd(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("add")
+	date := fs.String("date", "", "дата события YYYY-MM-DD (по умолчанию сегодня)")
+	title := fs.String("title", "", "заголовок")
+	content := fs.String("content", "", "описание")
+	output := fs.String("o", formatTable, "формат вывода: table, json или ics")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+
+	day, err := parseDate(*date, today())
+	if err != nil {
+		return err
+	}
+	if *title == "" {
+		return fmt.Errorf("add: укажите -title")
+	}
+
+	event, err := c.Create(ctx, *userID, day, *title, *content)
+	if err != nil {
+		return err
+	}
+
+	return printEvents(out, []*client.Event{event}, *output)
+}
+
+// cmdEdit меняет переданные поля события, остальные оставляет как есть
+func cmdEdit(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("edit")
+	id := fs.Int("id", 0, "ID события")
+	date := fs.String("date", "", "новая дата YYYY-MM-DD")
+	title := fs.String("title", "", "новый заголовок")
+	content := fs.String("content", "", "новое описание (пустая строка очищает)")
+	output := fs.String("o", formatTable, "формат вывода: table, json или ics")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return fmt.Errorf("edit: укажите -id")
+	}
+
+	event, err := c.Get(ctx, *userID, *id)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	var parseErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "date":
+			event.Date, parseErr = parseDate(*date, event.Date)
+			changed = true
+		case "title":
+			event.Title = *title
+			changed = true
+		case "content":
+			event.Content = *content
+			changed = true
+		}
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+	if !changed {
+		return fmt.Errorf("edit: укажите хотя бы одно из -date, -title, -content")
+	}
+
+	// версия из Get: если событие успели изменить, сервер откажет, а не затрёт чужую правку
+	updated, err := c.Update(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return printEvents(out, []*client.Event{updated}, *output)
+}
+
+// cmdDelete перемещает событие в корзину
+func cmdDelete(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("delete")
+	id := fs.Int("id", 0, "ID события")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return fmt.Errorf("delete: укажите -id")
+	}
+
+	event, err := c.Get(ctx, *userID, *id)
+	if err != nil {
+		return err
+	}
+	if err := c.Delete(ctx, *userID, *id, event.Version); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "событие %d перемещено в корзину\n", *id)
+
+	return nil
+}
+
+// cmdList выводит события за день, неделю, месяц или произвольный диапазон
+func cmdList(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("list")
+	period := fs.String("period", "week", "day, week, month или range")
+	date := fs.String("date", "", "дата внутри периода YYYY-MM-DD (по умолчанию сегодня)")
+	from := fs.String("from", "", "начало диапазона для range YYYY-MM-DD")
+	to := fs.String("to", "", "конец диапазона для range YYYY-MM-DD (включительно)")
+	output := fs.String("o", formatTable, "формат вывода: table, json или ics")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+
+	day, err := parseDate(*date, today())
+	if err != nil {
+		return err
+	}
+
+	var events []*client.Event
+	switch *period {
+	case "day":
+		events, err = c.EventsForDay(ctx, *userID, day)
+	case "week":
+		events, err = c.EventsForWeek(ctx, *userID, day)
+	case "month":
+		events, err = c.EventsForMonth(ctx, *userID, day)
+	case "range":
+		if *from == "" || *to == "" {
+			return fmt.Errorf("list: для -period range нужны -from и -to")
+		}
+		start, end, rangeErr := parseRange(*from, *to, 0)
+		if rangeErr != nil {
+			return rangeErr
+		}
+		events, err = fetchRange(ctx, c, *userID, start, end)
+	default:
+		return fmt.Errorf("list: неизвестный период %q", *period)
+	}
+	if err != nil {
+		return err
+	}
+
+	sortEvents(events)
+
+	return printEvents(out, events, *output)
+}
+
+// cmdSearch ищет текст в заголовке и описании событий за диапазон (без учёта регистра)
+func cmdSearch(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("search")
+	query := fs.String("q", "", "искомый текст")
+	from := fs.String("from", "", "начало диапазона YYYY-MM-DD (по умолчанию год назад)")
+	to := fs.String("to", "", "конец диапазона YYYY-MM-DD (по умолчанию через год)")
+	output := fs.String("o", formatTable, "формат вывода: table, json или ics")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*query) == "" {
+		return fmt.Errorf("search: укажите -q")
+	}
+
+	start, end, err := parseRange(*from, *to, searchSpan)
+	if err != nil {
+		return err
+	}
+
+	events, err := fetchRange(ctx, c, *userID, start, end)
+	if err != nil {
+		return err
+	}
+
+	needle := strings.ToLower(*query)
+	found := make([]*client.Event, 0)
+	for _, event := range events {
+		if strings.Contains(strings.ToLower(event.Title), needle) || strings.Contains(strings.ToLower(event.Content), needle) {
+			found = append(found, event)
+		}
+	}
+
+	return printEvents(out, found, *output)
+}
+
+// importedEvent - событие из JSON-файла (дата как YYYY-MM-DD или RFC 3339, как в export)
+type importedEvent struct {
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// cmdImport создаёт события из JSON или ICS файла
+func cmdImport(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("import")
+	file := fs.String("file", "", "файл с событиями (- для stdin)")
+	format := fs.String("format", "", "json или ics (по умолчанию по расширению или содержимому)")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("import: укажите -file")
+	}
+
+	var data []byte
+	var err error
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	if *format == "" {
+		*format = formatJSON
+		if strings.EqualFold(filepath.Ext(*file), ".ics") || strings.HasPrefix(strings.TrimSpace(string(data)), "BEGIN:VCALENDAR") {
+			*format = formatICS
+		}
+	}
+
+	var events []*client.Event
+	switch *format {
+	case formatICS:
+		if events, err = ical.Decode(strings.NewReader(string(data))); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	case formatJSON:
+		var items []importedEvent
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("import: ожидается JSON-массив событий: %w", err)
+		}
+		for i, item := range items {
+			date, err := time.Parse(dateLayout, item.Date)
+			if err != nil {
+				if date, err = time.Parse(time.RFC3339, item.Date); err != nil {
+					return fmt.Errorf("import: событие %d: неверная дата %q", i+1, item.Date)
+				}
+			}
+			events = append(events, &client.Event{Date: date, Title: item.Title, Content: item.Content})
+		}
+	default:
+		return fmt.Errorf("import: неизвестный формат %q", *format)
+	}
+
+	for i, event := range events {
+		if _, err := c.Create(ctx, *userID, event.Date, event.Title, event.Content); err != nil {
+			return fmt.Errorf("import: событие %d (%s): %w (импортировано %d)", i+1, event.Title, err, i)
+		}
+	}
+
+	fmt.Fprintf(out, "импортировано событий: %d\n", len(events))
+
+	return nil
+}
+
+// cmdExport выводит события за диапазон в файл-совместимом формате
+func cmdExport(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("export")
+	from := fs.String("from", "", "начало диапазона YYYY-MM-DD (по умолчанию год назад)")
+	to := fs.String("to", "", "конец диапазона YYYY-MM-DD (по умолчанию через год)")
+	output := fs.String("o", formatICS, "формат: ics, json или table")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+
+	start, end, err := parseRange(*from, *to, searchSpan)
+	if err != nil {
+		return err
+	}
+
+	events, err := fetchRange(ctx, c, *userID, start, end)
+	if err != nil {
+		return err
+	}
+
+	return printEvents(out, events, *output)
+}
+
+// cmdMonth рисует месяц сеткой и перечисляет его события
+func cmdMonth(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+
+	fs, userID := newFlagSet("month")
+	date := fs.String("date", "", "любая дата месяца YYYY-MM-DD (по умолчанию сегодня)")
+	if err := parseFlags(fs, userID, args); err != nil {
+		return err
+	}
+
+	day, err := parseDate(*date, today())
+	if err != nil {
+		return err
+	}
+
+	events, err := c.EventsForMonth(ctx, *userID, day)
+	if err != nil {
+		return err
+	}
+	sortEvents(events)
+
+	renderMonth(out, day, events, today())
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/IPampurin/calendar-server/pkg/client"
+)
+
+const serverDefault = "http://localhost:8081"
+
+// command - подкоманда calctl
+type command struct {
+	usage string                                                                          // строка для справки
+	run   func(ctx context.Context, c *client.Client, args []string, out io.Writer) error // выполнение
+}
+
+var commands = map[string]command{
+	"add":    {"add -user ID -date YYYY-MM-DD -title TEXT [-content TEXT]", cmdAdd},
+	"edit":   {"edit -user ID -id EVENT [-date YYYY-MM-DD] [-title TEXT] [-content TEXT]", cmdEdit},
+	"delete": {"delete -user ID -id EVENT", cmdDelete},
+	"list":   {"list -user ID [-period day|week|month|range] [-date YYYY-MM-DD] [-from .. -to ..] [-o table|json|ics]", cmdList},
+	"search": {"search -user ID -q TEXT [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-o table|json|ics]", cmdSearch},
+	"import": {"import -user ID -file PATH|- [-format json|ics]", cmdImport},
+	"export": {"export -user ID [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-o json|ics|table]", cmdExport},
+	"month":  {"month -user ID [-date YYYY-MM-DD]", cmdMonth},
+}
+
+func main() {
+
+	// Ctrl+C прерывает текущий запрос
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "calctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run разбирает общие флаги и запускает подкоманду
+func run(ctx context.Context, args []string, out, errOut io.Writer) error {
+
+	fs := flag.NewFlagSet("calctl", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	serverURL := fs.String("server", envOr("CALCTL_SERVER", serverDefault), "адрес сервера (CALCTL_SERVER)")
+	token := fs.String("token", os.Getenv("CALCTL_TOKEN"), "токен авторизации (CALCTL_TOKEN)")
+	fs.Usage = func() { usage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("не указана команда")
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("неизвестная команда %q", fs.Arg(0))
+	}
+
+	opts := []client.Option{}
+	if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+	c, err := client.New(*serverURL, opts...)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(ctx, c, fs.Args()[1:], out)
+}
+
+// usage печатает справку по командам
+func usage(fs *flag.FlagSet) {
+
+	w := fs.Output()
+	fmt.Fprintln(w, "Использование: calctl [-server URL] [-token TOKEN] <команда> [флаги]")
+	fmt.Fprintln(w, "\nКоманды:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+
+	fmt.Fprintln(w, "\nОбщие флаги:")
+	fs.PrintDefaults()
+}
+
+// envOr возвращает переменную окружения или значение по умолчанию
+func envOr(name, fallback string) string {
+
+	if value, ok := os.LookupEnv(name); ok && strings.TrimSpace(value) != "" {
+		return value
+	}
+
+	return fallback
+}
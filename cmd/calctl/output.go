@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/client"
+	"github.com/IPampurin/calendar-server/pkg/ical"
+)
+
+// printEvents выводит события в выбранном формате
+func printEvents(out io.Writer, events []*client.Event, format string) error {
+
+	switch format {
+	case formatTable:
+		return printTable(out, events)
+	case formatJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	case formatICS:
+		return ical.Encode(out, events)
+	}
+
+	return fmt.Errorf("неизвестный формат вывода %q (table, json или ics)", format)
+}
+
+// printTable выводит события таблицей
+func printTable(out io.Writer, events []*client.Event) error {
+
+	if len(events) == 0 {
+		_, err := fmt.Fprintln(out, "событий нет")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tДАТА\tВЕРСИЯ\tЗАГОЛОВОК\tОПИСАНИЕ")
+	for _, event := range events {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n",
+			event.ID, event.Date.Format(dateLayout), event.Version, oneLine(event.Title), oneLine(event.Content))
+	}
+
+	return tw.Flush()
+}
+
+// oneLine сворачивает многострочный текст для таблицы
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var monthNames = [...]string{
+	"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь",
+	"Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь",
+}
+
+// renderMonth рисует месяц сеткой (неделя с понедельника): дни с событиями помечены *,
+// сегодняшний день - в квадратных скобках; под сеткой - список событий
+func renderMonth(out io.Writer, date time.Time, events []*client.Event, now time.Time) {
+
+	first := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	days := first.AddDate(0, 1, -1).Day()
+
+	busy := make(map[int]bool)
+	for _, event := range events {
+		busy[event.Date.Day()] = true
+	}
+
+	title := fmt.Sprintf("%s %d", monthNames[first.Month()-1], first.Year())
+	width := 7 * 5 // ячейка: скобка, две цифры, скобка, пометка
+	fmt.Fprintf(out, "%*s\n", (width+len([]rune(title)))/2, title)
+	fmt.Fprintln(out, " Пн   Вт   Ср   Чт   Пт   Сб   Вс")
+
+	// сдвиг первого дня: понедельник - 0, воскресенье - 6
+	offset := (int(first.Weekday()) + 6) % 7
+	var line strings.Builder
+	line.WriteString(strings.Repeat("     ", offset))
+	for day := 1; day <= days; day++ {
+		left, right, mark := " ", " ", " "
+		if now.Year() == first.Year() && now.Month() == first.Month() && now.Day() == day {
+			left, right = "[", "]"
+		}
+		if busy[day] {
+			mark = "*"
+		}
+		fmt.Fprintf(&line, "%s%2d%s%s", left, day, right, mark)
+
+		if (offset+day)%7 == 0 || day == days {
+			fmt.Fprintln(out, strings.TrimRight(line.String(), " "))
+			line.Reset()
+		}
+	}
+
+	if len(events) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	for _, event := range events {
+		fmt.Fprintf(out, "%2d  #%d %s\n", event.Date.Day(), event.ID, oneLine(event.Title))
+	}
+}
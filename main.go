@@ -1,19 +1,38 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 
+	"github.com/IPampurin/calendar-server/pkg/config"
 	"github.com/IPampurin/calendar-server/pkg/server"
 	"github.com/IPampurin/calendar-server/pkg/storage"
 )
 
 func main() {
 
+	// читаем настройки: файл, .env, окружение, флаги
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Ошибка настройки сервера: %v\n", err)
+		os.Exit(2)
+	}
+
 	// создаём хранилище
 	db := storage.NewStorage()
+	db.ChangeLogLimit = cfg.Storage.ChangeLogLimit
+	db.TrashRetention = cfg.Storage.TrashRetention
+	db.MaxEventsPerUser = cfg.Storage.MaxEventsPerUser
+	db.MaxTitleLength = cfg.Storage.MaxTitleLength
+	db.MaxContentLength = cfg.Storage.MaxContentLength
 
 	// запускаем сервер
-	if err := server.Run(db); err != nil {
+	if err := server.Run(db, cfg); err != nil {
 		fmt.Printf("Ошибка запуска сервера: %v\n", err)
 		return
 	}
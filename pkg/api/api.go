@@ -2,26 +2,157 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/IPampurin/calendar-server/pkg/tracing"
+	"github.com/IPampurin/calendar-server/pkg/webhook"
 )
 
 type API struct {
-	Storage storage.Repository
+	Storage  storage.Repository
+	Webhooks *webhook.Dispatcher // nil - вебхуки не настроены
+
+	idempotency *idempotencyStore // сохранённые ответы для Idempotency-Key
+	maxBodySize int64             // сколько байт тела запроса можно прочитать (меньше нуля - без ограничения)
+
+	prefix     string                            // префикс путей всех маршрутов (например, /calendar)
+	middleware []func(http.Handler) http.Handler // обёртки вокруг роутера (первая - внешняя)
+}
+
+// Option настраивает дополнительные возможности API
+type Option func(*API)
+
+// WithWebhooks подключает управление подписками на вебхуки
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(api *API) {
+		api.Webhooks = d
+	}
+}
+
+// WithPrefix монтирует все маршруты под префиксом пути (например, /calendar)
+func WithPrefix(prefix string) Option {
+	return func(api *API) {
+		api.prefix = "/" + strings.Trim(prefix, "/")
+		if api.prefix == "/" {
+			api.prefix = ""
+		}
+	}
+}
+
+// WithMiddleware оборачивает роутер в middleware (первый переданный выполняется первым)
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(api *API) {
+		api.middleware = append(api.middleware, middleware...)
+	}
 }
 
-func NewAPI(db storage.Repository) *API {
-	return &API{Storage: db}
+func NewAPI(db storage.Repository, opts ...Option) *API {
+
+	api := &API{Storage: db, idempotency: newIdempotencyStore(0), maxBodySize: maxBodySizeDefault}
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	return api
+}
+
+// Route - маршрут API: шаблон для http.ServeMux ("МЕТОД /путь") и хэндлер
+type Route struct {
+	Pattern string
+	Handler http.HandlerFunc
+}
+
+// Routes возвращает все маршруты API
+// (все POST, а также PUT и DELETE v2 обёрнуты в Idempotent: повтор с тем же Idempotency-Key получает первый ответ;
+// каждый маршрут должен быть описан в openapi.json)
+func (api *API) Routes() []Route {
+
+	return []Route{
+		{"POST /create_event", api.Idempotent(api.CreateEventHandler)},   // POST — создание нового события
+		{"POST /update_event", api.Idempotent(api.UpdateEventHandler)},   // POST — обновление существующего
+		{"POST /delete_event", api.Idempotent(api.DeleteEventHandler)},   // POST — удаление (в корзину)
+		{"POST /batch_events", api.Idempotent(api.BatchEventsHandler)},   // POST — пакет операций (в т.ч. атомарно)
+		{"POST /restore_event", api.Idempotent(api.RestoreEventHandler)}, // POST — восстановление из корзины
+		{"POST /purge_event", api.Idempotent(api.PurgeEventHandler)},     // POST — удаление из корзины навсегда
+		{"GET /trash", api.GetTrashHandler},                              // GET — содержимое корзины
+		{"GET /event", api.GetEventHandler},                              // GET — одно событие (с ETag)
+		{"PATCH /event", api.PatchEventHandler},                          // PATCH — частичное обновление
+		{"GET /events_for_day", api.GetEventsForDayHandler},              // GET — события на день
+		{"GET /events_for_week", api.GetEventsForWeekHandler},            // GET — события на неделю
+		{"GET /events_for_month", api.GetEventsForMonthHandler},          // GET — события на месяц
+		{"GET /sync", api.SyncHandler},                                   // GET — изменения с последней синхронизации
+		{"GET /event_history", api.GetEventHistoryHandler},               // GET — история изменений события
+		{"GET /event_revision", api.GetEventRevisionHandler},             // GET — конкретная версия события
+		{"POST /revert_event", api.Idempotent(api.RevertEventHandler)},   // POST — откат к версии
+
+		// v2 — ресурсный API (v1 выше продолжает работать)
+		{"GET /v2/users/{id}/events", api.V2ListEventsHandler},                               // GET — события за период
+		{"POST /v2/users/{id}/events", api.Idempotent(api.V2CreateEventHandler)},             // POST — создание события
+		{"GET /v2/users/{id}/events/{eventID}", api.V2GetEventHandler},                       // GET — одно событие
+		{"PUT /v2/users/{id}/events/{eventID}", api.Idempotent(api.V2ReplaceEventHandler)},   // PUT — замена события
+		{"PATCH /v2/users/{id}/events/{eventID}", api.V2PatchEventHandler},                   // PATCH — частичное обновление
+		{"DELETE /v2/users/{id}/events/{eventID}", api.Idempotent(api.V2DeleteEventHandler)}, // DELETE — удаление (в корзину)
+
+		{"POST /create_webhook", api.Idempotent(api.CreateWebhookHandler)}, // POST — подписка на изменения
+		{"POST /delete_webhook", api.Idempotent(api.DeleteWebhookHandler)}, // POST — удаление подписки
+		{"GET /webhooks", api.GetWebhooksHandler},                          // GET — список подписок
+		{"GET /webhook_deliveries", api.GetWebhookDeliveriesHandler},       // GET — история доставок подписки
+
+		{"GET /openapi.json", api.OpenAPIHandler},  // GET — спецификация OpenAPI
+		{"GET /docs", api.DocsHandler},             // GET — интерактивная документация
+		{"GET /docs/{file}", api.DocsAssetHandler}, // GET — скрипт и стили Swagger UI
+	}
+}
+
+// Handler собирает роутер со всеми маршрутами API (с префиксом и middleware из опций)
+func (api *API) Handler() http.Handler {
+
+	mux := http.NewServeMux()
+	for _, route := range api.Routes() {
+		mux.HandleFunc(api.pattern(route), route.Handler)
+	}
+
+	return api.wrap(api.limitBody(tracing.RouteMiddleware(mux)))
+}
+
+// repo возвращает хранилище с методами *Context: вызовы записываются дочерними span запроса
+// и прерываются, если клиент ушёл
+func (api *API) repo() storage.ContextRepository {
+	return tracing.Repository(storage.WithContext(api.Storage))
+}
+
+// NewHandler создаёт API и возвращает его роутер - его можно смонтировать в свой сервер
+// или запустить несколько независимых экземпляров в одном процессе
+func NewHandler(db storage.Repository, opts ...Option) http.Handler {
+	return NewAPI(db, opts...).Handler()
+}
+
+// pattern добавляет префикс к пути маршрута
+func (api *API) pattern(route Route) string {
+
+	method, path, _ := strings.Cut(route.Pattern, " ")
+
+	return method + " " + api.prefix + path
+}
+
+// wrap оборачивает обработчик в middleware
+func (api *API) wrap(handler http.Handler) http.Handler {
+
+	for i := len(api.middleware) - 1; i >= 0; i-- {
+		handler = api.middleware[i](handler)
+	}
+
+	return handler
 }
 
-func Init(db storage.Repository) {
+// Init регистрирует маршруты на http.DefaultServeMux
+// (оставлено для совместимости: повторный вызов в одном процессе паникует, используйте NewHandler)
+func Init(db storage.Repository, opts ...Option) {
 
-	api := NewAPI(db)
+	api := NewAPI(db, opts...)
 
-	http.HandleFunc("POST /create_event", api.CreateEventHandler)          // POST — создание нового события
-	http.HandleFunc("POST /update_event", api.UpdateEventHandler)          // POST — обновление существующего
-	http.HandleFunc("POST /delete_event", api.DeleteEventHandler)          // POST — удаление
-	http.HandleFunc("GET /events_for_day", api.GetEventsForDayHandler)     // GET — события на день
-	http.HandleFunc("GET /events_for_week", api.GetEventsForWeekHandler)   // GET — события на неделю
-	http.HandleFunc("GET /events_for_month", api.GetEventsForMonthHandler) // GET — события на месяц
+	for _, route := range api.Routes() {
+		http.Handle(api.pattern(route), api.wrap(api.limitBody(route.Handler)))
+	}
 }
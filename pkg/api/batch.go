@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/IPampurin/calendar-server/pkg/tracing"
+)
+
+// maxBatchOperations - сколько операций можно передать в одном запросе
+const maxBatchOperations = 1000
+
+// batchOperation - одна операция пакета
+type batchOperation struct {
+	Op      string `json:"op"`                 // create / update / delete
+	ID      int    `json:"id,omitempty"`       // ID события для update
+	EventID int    `json:"event_id,omitempty"` // ID события для delete
+	UserID  int    `json:"user_id"`
+	Date    string `json:"date,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Content string `json:"content,omitempty"`
+	Version int    `json:"version,omitempty"` // обязательна для update и delete
+}
+
+// BatchResult - результат одной операции пакета
+type BatchResult struct {
+	Index  int            `json:"index"`           // номер операции в запросе
+	Op     string         `json:"op"`              // тип операции
+	Status int            `json:"status"`          // HTTP-статус, который получила бы одиночная операция
+	Event  *storage.Event `json:"event,omitempty"` // событие после create/update
+	Error  string         `json:"error,omitempty"` // причина ошибки
+}
+
+// BatchResponse - результат всего пакета
+type BatchResponse struct {
+	Atomic  bool           `json:"atomic"`  // выполнялся ли пакет одной транзакцией
+	Applied bool           `json:"applied"` // все ли операции применены
+	Results []*BatchResult `json:"results"` // результаты по порядку операций
+}
+
+// batchError - ошибка операции вместе с HTTP-статусом
+type batchError struct {
+	status int
+	err    error
+}
+
+func (e *batchError) Error() string { return e.err.Error() }
+
+/*
+POST /batch_events
+{
+  "atomic": true,
+  "operations": [
+    {"op": "create", "user_id": 123, "date": "2026-01-15", "title": "Планёрка"},
+    {"op": "update", "id": 5, "user_id": 123, "date": "2026-01-16", "title": "Ретро", "version": 2},
+    {"op": "delete", "user_id": 123, "event_id": 7, "version": 1}
+  ]
+}
+*/
+// BatchEventsHandler выполняет пакет операций с событиями
+// (atomic: true - все операции одной транзакцией хранилища, иначе каждая сама по себе)
+func (api *API) BatchEventsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	// структура для парсинга запроса
+	var req struct {
+		Atomic     bool              `json:"atomic"`
+		Operations []*batchOperation `json:"operations"`
+	}
+
+	// читаем запрос
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	// определяем структуру
+	err = json.Unmarshal(buf.Bytes(), &req)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно десериализовать тело запроса %v", err.Error())
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		answer.Error = "список operations пуст"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		answer.Error = fmt.Sprintf("не больше %d операций в одном запросе", maxBatchOperations)
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	response := &BatchResponse{Atomic: req.Atomic, Results: make([]*BatchResult, len(req.Operations))}
+	for i, op := range req.Operations {
+		response.Results[i] = &BatchResult{Index: i, Op: op.Op}
+	}
+
+	if !req.Atomic {
+		response.Applied = true
+		for i, op := range req.Operations {
+			if err := runBatchOperation(contextTx{ctx: r.Context(), db: api.repo()}, op, response.Results[i]); err != nil {
+				response.Applied = false
+			}
+		}
+		answer.Result = response
+		WriterJSON(w, http.StatusOK, answer) // 200
+		return
+	}
+
+	// атомарный режим требует поддержки транзакций от хранилища
+	transactional, ok := api.Storage.(storage.Transactional)
+	if !ok {
+		answer.Error = "хранилище не поддерживает транзакции"
+		WriterJSON(w, http.StatusNotImplemented, answer) // 501
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "storage.Atomic")
+	err = transactional.AtomicContext(ctx, func(tx storage.Tx) error {
+		for i, op := range req.Operations {
+			if err := runBatchOperation(tx, op, response.Results[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+
+	if err != nil {
+		// транзакция откатилась - успешные до ошибки операции тоже не применены
+		for _, result := range response.Results {
+			if result.Status != 0 && result.Error == "" {
+				result.Status = http.StatusFailedDependency
+				result.Event = nil
+				result.Error = "не применено: транзакция отменена"
+			} else if result.Status == 0 {
+				result.Status = http.StatusFailedDependency
+				result.Error = "не выполнялось: транзакция отменена"
+			}
+		}
+
+		status := http.StatusServiceUnavailable
+		var opErr *batchError
+		if errors.As(err, &opErr) {
+			status = opErr.status
+		}
+
+		answer.Result = response
+		answer.Error = err.Error()
+		WriterJSON(w, status, answer)
+		return
+	}
+
+	response.Applied = true
+	answer.Result = response
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+// contextTx - операции пакета вне транзакции: каждая идёт в хранилище со своим ctx
+type contextTx struct {
+	ctx context.Context
+	db  storage.ContextRepository
+}
+
+func (tx contextTx) Create(userID int, date time.Time, title, content string) (int, error) {
+	return tx.db.CreateContext(tx.ctx, userID, date, title, content)
+}
+
+func (tx contextTx) UpdateBy(actorID int, event *storage.Event) error {
+	return tx.db.UpdateByContext(tx.ctx, actorID, event)
+}
+
+func (tx contextTx) DeleteIfMatch(userID, eventID, version int) error {
+	return tx.db.DeleteIfMatchContext(tx.ctx, userID, eventID, version)
+}
+
+func (tx contextTx) Get(userID, eventID int) (*storage.Event, error) {
+	return tx.db.GetContext(tx.ctx, userID, eventID)
+}
+
+// runBatchOperation выполняет одну операцию и заполняет её результат
+// (target - само хранилище или транзакция: у них одинаковый набор методов)
+func runBatchOperation(target storage.Tx, op *batchOperation, result *BatchResult) error {
+
+	event, status, err := applyBatchOperation(target, op)
+
+	result.Status = status
+	result.Event = event
+	if err != nil {
+		result.Error = err.Error()
+		return &batchError{status: status, err: fmt.Errorf("операция %d: %w", result.Index, err)}
+	}
+
+	return nil
+}
+
+// applyBatchOperation валидирует и применяет операцию, возвращает событие и HTTP-статус
+func applyBatchOperation(target storage.Tx, op *batchOperation) (*storage.Event, int, error) {
+
+	if op == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("операция не может быть null")
+	}
+	if op.UserID <= 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("user_id должен быть положительным числом")
+	}
+
+	switch op.Op {
+	case "create", "update":
+		date, err := time.Parse("2006-01-02", op.Date)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("используйте YYYY-MM-DD, неверный формат даты, ошибка: %v", err.Error())
+		}
+		if op.Title == "" {
+			return nil, http.StatusBadRequest, fmt.Errorf("поле title должно быть заполнено")
+		}
+
+		id := op.ID
+		status := http.StatusOK
+		if op.Op == "create" {
+			if id, err = target.Create(op.UserID, date, op.Title, op.Content); err != nil {
+				return nil, v2StorageStatus(err), err
+			}
+			status = http.StatusCreated
+		} else {
+			if id <= 0 {
+				return nil, http.StatusBadRequest, fmt.Errorf("ID события должен быть положительным числом")
+			}
+			if op.Version <= 0 {
+				return nil, http.StatusPreconditionRequired, fmt.Errorf("укажите version события")
+			}
+			event := &storage.Event{ID: id, UserID: op.UserID, Date: date, Title: op.Title, Content: op.Content, Version: op.Version}
+			if err = target.UpdateBy(op.UserID, event); err != nil {
+				return nil, v2StorageStatus(err), err
+			}
+		}
+
+		event, err := target.Get(op.UserID, id)
+		if err != nil {
+			return nil, v2StorageStatus(err), err
+		}
+		return event, status, nil
+
+	case "delete":
+		if op.EventID <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("ID события должен быть положительным числом")
+		}
+		if op.Version <= 0 {
+			return nil, http.StatusPreconditionRequired, fmt.Errorf("укажите version события")
+		}
+		if err := target.DeleteIfMatch(op.UserID, op.EventID, op.Version); err != nil {
+			return nil, v2StorageStatus(err), err
+		}
+		return nil, http.StatusOK, nil
+	}
+
+	return nil, http.StatusBadRequest, fmt.Errorf("неизвестная операция %q, используйте create, update или delete", op.Op)
+}
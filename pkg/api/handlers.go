@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/IPampurin/calendar-server/pkg/storage"
@@ -13,12 +14,33 @@ import (
 
 // Answer - универсальная структура для возврата ответа
 type Answer struct {
-	Result interface{} `json:"result,omitempty"`
-	Error  string      `json:"error,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"` // ID запроса для поиска в журнале (только у внутренних ошибок)
+}
+
+// wantsRepresentation проверяет, просит ли клиент вернуть событие целиком вместо строки
+// (заголовок Prefer: return=representation или параметр ?return=event)
+func wantsRepresentation(r *http.Request) bool {
+
+	if r.URL.Query().Get("return") == "event" {
+		return true
+	}
+
+	for _, prefer := range r.Header.Values("Prefer") {
+		for _, token := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "return=representation") {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 /* POST /create_event
 Content-Type: application/json
+Prefer: return=representation (необязательно, в result придёт событие целиком)
 {
   "user_id": 123,
   "date": "2026-01-15",
@@ -44,7 +66,7 @@ func (api *API) CreateEventHandler(w http.ResponseWriter, r *http.Request) {
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
 		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
-		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
 		return
 	}
 
@@ -80,26 +102,43 @@ func (api *API) CreateEventHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// вызываем storage
-	id, err := api.Storage.Create(req.UserID, date, req.Title, req.Content)
+	id, err := api.repo().CreateContext(r.Context(), req.UserID, date, req.Title, req.Content)
 	if err != nil {
 		answer.Error = err.Error()
-		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		WriterJSON(w, storageErrorStatus(err), answer) // 413 / 429 / 503
 		return
 	}
 
 	answer.Result = fmt.Sprintf("событие создано, ID: %d", id)
 
+	// по запросу отдаём сохранённое событие вместо строки
+	if wantsRepresentation(r) {
+		event, err := api.repo().GetContext(r.Context(), req.UserID, id)
+		if err != nil {
+			answer.Error = err.Error()
+			WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+			return
+		}
+		w.Header().Set("Preference-Applied", "return=representation")
+		w.Header().Set("ETag", etag(event.Version))
+		answer.Result = event
+	}
+
 	WriterJSON(w, http.StatusCreated, answer) // 201 тут логичнее
 }
 
 /*
 POST /update_event
+X-Actor-ID: 456 (необязательно, кто вносит изменение; по умолчанию user_id)
+If-Match: "1" (или поле version в теле)
+Prefer: return=representation (необязательно, в result придёт событие целиком)
 {
   "id": 5,
   "user_id": 123,
   "date": "2026-01-15",
   "title": "Новое название",
-  "content": "Новое описание"
+  "content": "Новое описание",
+  "version": 1
 }
 */
 // UpdateEventHandler обрабатывет запрос на обновление события
@@ -115,13 +154,14 @@ func (api *API) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 		Date    string `json:"date"`    // новая дата
 		Title   string `json:"title"`   // новый заголовок
 		Content string `json:"content,omitempty"`
+		Version int    `json:"version,omitempty"` // версия, которую клиент изменяет (можно в If-Match)
 	}
 
 	// читаем запрос
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
 		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
-		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
 		return
 	}
 
@@ -158,6 +198,22 @@ func (api *API) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// версия, на которую рассчитывает клиент
+	version, err := expectedVersion(r, req.Version)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, versionErrorStatus(err), answer) // 428 / 400
+		return
+	}
+
+	// автор изменения для истории (по умолчанию - владелец)
+	actorID, err := actorFromRequest(r, req.UserID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
 	// создаем экземпляр события
 	event := &storage.Event{
 		ID:      req.ID,
@@ -165,25 +221,36 @@ func (api *API) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 		Date:    date,
 		Title:   req.Title,
 		Content: req.Content,
+		Version: version,
 	}
 
 	// вызываем storage
-	if err := api.Storage.Update(event); err != nil {
+	if err := api.repo().UpdateByContext(r.Context(), actorID, event); err != nil {
 		answer.Error = err.Error()
-		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		WriterJSON(w, storageErrorStatus(err), answer) // 412 / 413 / 429 / 503
 		return
 	}
 
 	answer.Result = "событие обновлено"
 
+	// отдаём новую версию, чтобы клиент мог сразу обновлять дальше
+	// (хранилище записало её в event - повторное чтение могло бы вернуть уже чужую правку)
+	w.Header().Set("ETag", etag(event.Version))
+	if wantsRepresentation(r) {
+		w.Header().Set("Preference-Applied", "return=representation")
+		answer.Result = event
+	}
+
 	WriterJSON(w, http.StatusOK, answer) // 200
 }
 
 /*
 POST /delete_event
+If-Match: "2" (или поле version в теле)
 {
   "user_id": 123,
-  "event_id": 5
+  "event_id": 5,
+  "version": 2
 }
 */
 // DeleteEventHandler обрабатывет запрос на удаление события
@@ -196,13 +263,14 @@ func (api *API) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		UserID  int `json:"user_id"`
 		EventID int `json:"event_id"`
+		Version int `json:"version,omitempty"`
 	}
 
 	// читаем запрос
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
 		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
-		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
 		return
 	}
 
@@ -226,10 +294,18 @@ func (api *API) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// версия, на которую рассчитывает клиент
+	version, err := expectedVersion(r, req.Version)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, versionErrorStatus(err), answer) // 428 / 400
+		return
+	}
+
 	// вызываем storage
-	if err := api.Storage.Delete(req.UserID, req.EventID); err != nil {
+	if err := api.repo().DeleteIfMatchContext(r.Context(), req.UserID, req.EventID, version); err != nil {
 		answer.Error = err.Error()
-		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		WriterJSON(w, storageErrorStatus(err), answer) // 412 / 413 / 429 / 503
 		return
 	}
 
@@ -270,7 +346,7 @@ func (api *API) GetEventsForDayHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// вызываем storage
-	events, err := api.Storage.GetForDay(userID, date)
+	events, err := api.repo().GetForDayContext(r.Context(), userID, date)
 	if err != nil {
 		answer.Error = err.Error()
 		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
@@ -314,7 +390,7 @@ func (api *API) GetEventsForWeekHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// вызываем storage
-	events, err := api.Storage.GetForWeek(userID, date)
+	events, err := api.repo().GetForWeekContext(r.Context(), userID, date)
 	if err != nil {
 		answer.Error = err.Error()
 		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
@@ -358,7 +434,7 @@ func (api *API) GetEventsForMonthHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// вызываем storage
-	events, err := api.Storage.GetForMonth(userID, date)
+	events, err := api.repo().GetForMonthContext(r.Context(), userID, date)
 	if err != nil {
 		answer.Error = err.Error()
 		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// HeaderActorID - заголовок с ID пользователя, который вносит изменение (для истории событий)
+const HeaderActorID = "X-Actor-ID"
+
+// actorFromRequest возвращает автора изменения из заголовка X-Actor-ID или владельца события
+func actorFromRequest(r *http.Request, ownerID int) (int, error) {
+
+	actorStr := r.Header.Get(HeaderActorID)
+	if actorStr == "" {
+		return ownerID, nil
+	}
+
+	actorID, err := strconv.Atoi(actorStr)
+	if err != nil || actorID <= 0 {
+		return 0, fmt.Errorf("заголовок %s должен быть положительным числом", HeaderActorID)
+	}
+
+	return actorID, nil
+}
+
+// GET /event_history?user_id=123&event_id=5
+// GetEventHistoryHandler обрабатывает запрос на чтение истории изменений события
+func (api *API) GetEventHistoryHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	// парсим query параметры
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный user_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+	eventID, err := strconv.Atoi(r.URL.Query().Get("event_id"))
+	if err != nil || eventID <= 0 {
+		answer.Error = "неверный event_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	// вызываем storage
+	revisions, err := api.repo().HistoryContext(r.Context(), userID, eventID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusNotFound, answer) // 404
+		return
+	}
+
+	answer.Result = revisions
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+// GET /event_revision?user_id=123&event_id=5&revision=2
+// GetEventRevisionHandler обрабатывает запрос на чтение конкретной версии события
+func (api *API) GetEventRevisionHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	// парсим query параметры
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный user_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+	eventID, err := strconv.Atoi(r.URL.Query().Get("event_id"))
+	if err != nil || eventID <= 0 {
+		answer.Error = "неверный event_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+	number, err := strconv.Atoi(r.URL.Query().Get("revision"))
+	if err != nil || number <= 0 {
+		answer.Error = "неверный номер версии"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	// вызываем storage
+	revision, err := api.repo().RevisionContext(r.Context(), userID, eventID, number)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusNotFound, answer) // 404
+		return
+	}
+
+	answer.Result = revision
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+/*
+POST /revert_event
+X-Actor-ID: 456 (необязательно)
+{
+  "user_id": 123,
+  "event_id": 5,
+  "revision": 2
+}
+*/
+// RevertEventHandler обрабатывает запрос на откат события к прежней версии
+func (api *API) RevertEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	// структура для парсинга запроса
+	var req struct {
+		UserID   int `json:"user_id"`
+		EventID  int `json:"event_id"`
+		Revision int `json:"revision"`
+	}
+
+	// читаем запрос
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	// определяем структуру
+	err = json.Unmarshal(buf.Bytes(), &req)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно десериализовать тело запроса %v", err.Error())
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// проверка обязательных полей
+	if req.EventID <= 0 {
+		answer.Error = "ID события должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+	if req.UserID <= 0 {
+		answer.Error = "user_id должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+	if req.Revision <= 0 {
+		answer.Error = "номер версии должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	actorID, err := actorFromRequest(r, req.UserID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// вызываем storage
+	event, err := api.repo().RevertContext(r.Context(), req.UserID, req.EventID, req.Revision, actorID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, storageErrorStatus(err), answer) // 413 / 503
+		return
+	}
+
+	answer.Result = event
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
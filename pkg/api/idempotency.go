@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderIdempotencyKey - ключ, по которому повтор запроса получает первый ответ
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed выставляется в ответе, отданном из сохранённых
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	idempotencyWindowDefault = 24 * time.Hour // сколько хранится первый ответ
+	idempotencyKeyMaxLen     = 255
+	idempotencySweepInterval = time.Minute // как часто выбрасывать просроченные ключи
+	idempotencyKeysPerUser   = 1000        // сколько ключей хранится на пользователя (старые вытесняются)
+)
+
+// replayedHeaders - заголовки ответа, которые сохраняются вместе с телом
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Preference-Applied"}
+
+// idempotentResponse - сохранённый первый ответ на запрос с ключом
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte // метод, путь, параметры и тело запроса
+	done        bool              // false - первый запрос ещё выполняется
+	status      int
+	header      http.Header
+	body        []byte
+	createdAt   time.Time
+	expiresAt   time.Time
+}
+
+// idempotencyStore хранит ответы по пользователю и ключу
+type idempotencyStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	responses map[int]map[string]*idempotentResponse
+	lastSweep time.Time
+}
+
+func newIdempotencyStore(window time.Duration) *idempotencyStore {
+
+	if window <= 0 {
+		window = idempotencyWindowDefault
+	}
+
+	return &idempotencyStore{
+		window:    window,
+		responses: make(map[int]map[string]*idempotentResponse),
+	}
+}
+
+// WithIdempotencyWindow задаёт, сколько хранить ответы для Idempotency-Key (0 - 24 часа)
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(api *API) {
+		api.idempotency = newIdempotencyStore(window)
+	}
+}
+
+// sweep выбрасывает просроченные ответы (вызывается под mu)
+func (st *idempotencyStore) sweep(now time.Time) {
+
+	if now.Sub(st.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	st.lastSweep = now
+
+	for userID, keys := range st.responses {
+		for key, response := range keys {
+			if response.done && now.After(response.expiresAt) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(st.responses, userID)
+		}
+	}
+}
+
+// reserve освобождает место под новый ключ пользователя: вытесняет самый старый
+// завершённый ответ; false - все ключи заняты выполняющимися запросами (вызывается под mu)
+func (st *idempotencyStore) reserve(keys map[string]*idempotentResponse) bool {
+
+	if len(keys) < idempotencyKeysPerUser {
+		return true
+	}
+
+	oldest := ""
+	for key, response := range keys {
+		if response.done && (oldest == "" || response.createdAt.Before(keys[oldest].createdAt)) {
+			oldest = key
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	delete(keys, oldest)
+
+	return true
+}
+
+// recordingWriter пишет ответ клиенту и параллельно запоминает его
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotencyUser определяет, чей это ключ: ID из пути, параметр user_id,
+// поле user_id в теле или X-Actor-ID (0 - пользователь не указан)
+func idempotencyUser(r *http.Request, body []byte) int {
+
+	if userID, err := strconv.Atoi(r.PathValue("id")); err == nil {
+		return userID
+	}
+	if userID, err := strconv.Atoi(r.URL.Query().Get("user_id")); err == nil {
+		return userID
+	}
+
+	var payload struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.UserID != 0 {
+		return payload.UserID
+	}
+
+	if userID, err := strconv.Atoi(r.Header.Get(HeaderActorID)); err == nil {
+		return userID
+	}
+
+	return 0
+}
+
+// Idempotent оборачивает хэндлер: первый ответ на запрос с Idempotency-Key сохраняется
+// и отдаётся повторно на тот же ключ того же пользователя; ключ с другим запросом отклоняется
+// (ответы 5xx не сохраняются, чтобы повтор мог выполниться заново; сверх idempotencyKeysPerUser
+// ключей пользователя вытесняются самые старые)
+func (api *API) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var answer Answer
+
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			answer.Error = fmt.Sprintf("%s длиннее %d символов", HeaderIdempotencyKey, idempotencyKeyMaxLen)
+			WriterJSON(w, http.StatusBadRequest, answer) // 400
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+			WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n" + string(body)))
+		userID := idempotencyUser(r, body)
+
+		st := api.idempotency
+		now := time.Now()
+
+		st.mu.Lock()
+		st.sweep(now)
+		keys := st.responses[userID]
+		if keys == nil {
+			keys = make(map[string]*idempotentResponse)
+			st.responses[userID] = keys
+		}
+		saved, ok := keys[key]
+		if ok && saved.done && now.After(saved.expiresAt) {
+			ok = false
+		}
+		if ok {
+			// первый запрос дописывает ответ под st.mu - берём копию, пока держим блокировку
+			replay := *saved
+			st.mu.Unlock()
+
+			if replay.fingerprint != fingerprint {
+				answer.Error = fmt.Sprintf("%s уже использован с другим запросом", HeaderIdempotencyKey)
+				WriterJSON(w, http.StatusUnprocessableEntity, answer) // 422
+				return
+			}
+			if !replay.done {
+				answer.Error = fmt.Sprintf("запрос с этим %s ещё выполняется", HeaderIdempotencyKey)
+				WriterJSON(w, http.StatusConflict, answer) // 409
+				return
+			}
+
+			for name, values := range replay.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(HeaderIdempotentReplayed, "true")
+			w.WriteHeader(replay.status)
+			_, _ = w.Write(replay.body)
+			return
+		}
+
+		// просроченный ответ заменяется новым и места не занимает
+		if _, exists := keys[key]; !exists && !st.reserve(keys) {
+			st.mu.Unlock()
+			answer.Error = fmt.Sprintf("слишком много выполняющихся запросов с %s", HeaderIdempotencyKey)
+			WriterJSON(w, http.StatusTooManyRequests, answer) // 429
+			return
+		}
+
+		// резервируем ключ, пока выполняется первый запрос
+		pending := &idempotentResponse{fingerprint: fingerprint, createdAt: now}
+		keys[key] = pending
+		st.mu.Unlock()
+
+		rec := &recordingWriter{ResponseWriter: w}
+		defer func() {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+
+			if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				delete(keys, key)
+				return
+			}
+
+			pending.header = make(http.Header)
+			for _, name := range replayedHeaders {
+				if values := w.Header().Values(name); len(values) > 0 {
+					pending.header[name] = values
+				}
+			}
+			pending.status = rec.status
+			pending.body = rec.body.Bytes()
+			pending.expiresAt = time.Now().Add(st.window)
+			pending.done = true
+		}()
+
+		next(rec, r)
+	}
+}
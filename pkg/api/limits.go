@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxBodySizeDefault - сколько байт тела запроса читают хэндлеры, если не задано WithMaxBodySize
+const maxBodySizeDefault = 1 << 20 // 1 МБ
+
+// WithMaxBodySize ограничивает размер тела запроса (0 - 1 МБ, меньше нуля - без ограничения);
+// на тело больше лимита отвечаем 413
+func WithMaxBodySize(size int64) Option {
+	return func(api *API) {
+		api.maxBodySize = size
+		if size == 0 {
+			api.maxBodySize = maxBodySizeDefault
+		}
+	}
+}
+
+// limitBody ограничивает чтение тела запроса; если размер известен заранее
+// (Content-Length), слишком большой запрос отклоняется сразу, не читая тело
+func (api *API) limitBody(next http.Handler) http.Handler {
+
+	if api.maxBodySize < 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var answer Answer
+
+		if r.ContentLength > api.maxBodySize {
+			answer.Error = fmt.Sprintf("тело запроса больше %d байт", api.maxBodySize)
+			WriterJSON(w, http.StatusRequestEntityTooLarge, answer) // 413
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, api.maxBodySize)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyStatus подбирает HTTP-статус для ошибки чтения тела запроса
+func bodyStatus(err error) int {
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge // 413
+	}
+
+	return http.StatusBadRequest // 400
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// openAPISpec - описание всех маршрутов из Routes в формате OpenAPI 3.1
+// (при добавлении маршрута его нужно описать здесь, иначе упадёт тест)
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage - страница Swagger UI, которая читает openapi.json
+//
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI - скрипт и стили Swagger UI для docsPage (отдаются сервером, без CDN)
+//
+//go:embed swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-ui.css
+var swaggerUI embed.FS
+
+// OpenAPISpec возвращает спецификацию API
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// GET /openapi.json
+// OpenAPIHandler отдаёт спецификацию API
+func (api *API) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(api.spec())
+}
+
+// GET /docs
+// DocsHandler отдаёт интерактивную документацию
+func (api *API) DocsHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(docsPage)
+}
+
+// GET /docs/swagger-ui-bundle.js
+// DocsAssetHandler отдаёт встроенные файлы Swagger UI для страницы документации
+func (api *API) DocsAssetHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	// embed.FS сам отклоняет имена с ".." - выйти за пределы swagger-ui нельзя
+	name := r.PathValue("file")
+	data, err := swaggerUI.ReadFile("swagger-ui/" + name)
+	if err != nil {
+		answer.Error = "файл не найден"
+		WriterJSON(w, http.StatusNotFound, answer) // 404
+		return
+	}
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// spec возвращает спецификацию с учётом префикса маршрутов (servers указывает на префикс)
+func (api *API) spec() []byte {
+
+	if api.prefix == "" {
+		return openAPISpec
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		return openAPISpec
+	}
+	doc["servers"] = []map[string]string{{"url": api.prefix}}
+
+	patched, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return openAPISpec
+	}
+
+	return patched
+}
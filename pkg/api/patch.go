@@ -0,0 +1,581 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// типы содержимого, которые понимает PATCH
+const (
+	mergePatchType = "application/merge-patch+json" // RFC 7396
+	jsonPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+// errPatchTestFailed - операция test из JSON Patch не выполнилась
+var errPatchTestFailed = errors.New("операция test не прошла")
+
+// readOnlyFields - поля события, которые патчем менять нельзя
+var readOnlyFields = []string{"id", "user_id", "version"}
+
+/*
+PATCH /event?user_id=123&event_id=5
+If-Match: "3"
+Content-Type: application/merge-patch+json
+{
+  "date": "2026-01-16",
+  "content": null
+}
+
+или
+
+Content-Type: application/json-patch+json
+[
+  {"op": "test", "path": "/title", "value": "Встреча"},
+  {"op": "replace", "path": "/date", "value": "2026-01-16"},
+  {"op": "remove", "path": "/content"}
+]
+*/
+// PatchEventHandler обрабатывает запрос на частичное обновление события
+// (отсутствующее в патче поле не меняется, null в merge patch или remove в JSON Patch - очищает поле)
+func (api *API) PatchEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	// парсим query параметры
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный user_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+	eventID, err := strconv.Atoi(r.URL.Query().Get("event_id"))
+	if err != nil || eventID <= 0 {
+		answer.Error = "неверный event_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	// читаем запрос
+	_, err = buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	updated, status, err := api.patchEvent(r, userID, eventID, buf.Bytes())
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, status, answer)
+		return
+	}
+
+	w.Header().Set("ETag", etag(updated.Version))
+	answer.Result = updated
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+// patchEvent накладывает патч из тела запроса на событие и сохраняет результат,
+// при ошибке возвращает подходящий HTTP-статус (общая часть для /event и /v2)
+func (api *API) patchEvent(r *http.Request, userID, eventID int, body []byte) (*storage.Event, int, error) {
+
+	// версия, на которую рассчитывает клиент
+	version, err := expectedVersion(r, 0)
+	if err != nil {
+		return nil, versionErrorStatus(err), err // 428 / 400
+	}
+
+	actorID, err := actorFromRequest(r, userID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err // 400
+	}
+
+	// берём текущее состояние события
+	current, err := api.repo().GetContext(r.Context(), userID, eventID)
+	if err != nil {
+		return nil, http.StatusNotFound, err // 404
+	}
+
+	// накладываем патч на JSON-представление события
+	patched, err := applyPatch(r.Header.Get("Content-Type"), eventDocument(current), body)
+	if err != nil {
+		return nil, patchErrorStatus(err), err
+	}
+
+	// валидируем уже итоговое событие
+	event, err := documentEvent(patched, current)
+	if err != nil {
+		return nil, http.StatusBadRequest, err // 400
+	}
+	// патч собран из прочитанного выше состояния: даже при If-Match: * записываем его только поверх
+	// этой версии, иначе параллельное изменение между чтением и записью молча потеряется
+	event.Version = version
+	if event.Version == 0 {
+		event.Version = current.Version
+	}
+
+	// вызываем storage (в event он запишет сохранённое состояние с новой версией)
+	if err := api.repo().UpdateByContext(r.Context(), actorID, event); err != nil {
+		return nil, storageErrorStatus(err), err // 412 / 413 / 429 / 503
+	}
+
+	return event, http.StatusOK, nil
+}
+
+// patchErrorStatus подбирает HTTP-статус для ошибки наложения патча
+func patchErrorStatus(err error) int {
+
+	var unsupported *unsupportedPatchError
+
+	switch {
+	case errors.As(err, &unsupported):
+		return http.StatusUnsupportedMediaType // 415
+	case errors.Is(err, errPatchTestFailed):
+		return http.StatusConflict // 409
+	default:
+		return http.StatusBadRequest // 400
+	}
+}
+
+// unsupportedPatchError - неизвестный формат патча
+type unsupportedPatchError struct {
+	contentType string
+}
+
+func (e *unsupportedPatchError) Error() string {
+	return fmt.Sprintf("неподдерживаемый формат патча %q, используйте %s или %s", e.contentType, mergePatchType, jsonPatchType)
+}
+
+// applyPatch накладывает патч нужного формата на документ
+func applyPatch(contentType string, doc map[string]interface{}, body []byte) (map[string]interface{}, error) {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch mediaType {
+	case mergePatchType, "application/json":
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, fmt.Errorf("невозможно десериализовать патч %v", err.Error())
+		}
+		merged, ok := mergePatch(doc, patch).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("merge patch должен быть JSON-объектом")
+		}
+		return merged, nil
+
+	case jsonPatchType:
+		var ops []patchOperation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, fmt.Errorf("невозможно десериализовать патч %v", err.Error())
+		}
+		var target interface{} = doc
+		for i, op := range ops {
+			if target, err = op.apply(target); err != nil {
+				return nil, fmt.Errorf("операция %d (%s %s): %w", i, op.Op, op.Path, err)
+			}
+		}
+		patched, ok := target.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("после патча событие должно остаться JSON-объектом")
+		}
+		return patched, nil
+	}
+
+	return nil, &unsupportedPatchError{contentType: contentType}
+}
+
+// eventDocument представляет событие в том виде, в каком его видит клиент (дата - только день)
+func eventDocument(event *storage.Event) map[string]interface{} {
+
+	doc := map[string]interface{}{
+		"id":      float64(event.ID),
+		"user_id": float64(event.UserID),
+		"date":    event.Date.Format("2006-01-02"),
+		"title":   event.Title,
+		"version": float64(event.Version),
+	}
+	if event.Content != "" {
+		doc["content"] = event.Content
+	}
+
+	return doc
+}
+
+// documentEvent собирает событие из документа после патча и проверяет его
+func documentEvent(doc map[string]interface{}, current *storage.Event) (*storage.Event, error) {
+
+	original := eventDocument(current)
+	for _, field := range readOnlyFields {
+		if !jsonEqual(doc[field], original[field]) {
+			return nil, fmt.Errorf("поле %s нельзя изменить", field)
+		}
+	}
+
+	for field := range doc {
+		switch field {
+		case "id", "user_id", "version", "date", "title", "content":
+		default:
+			return nil, fmt.Errorf("неизвестное поле %s", field)
+		}
+	}
+
+	dateStr, ok := doc["date"].(string)
+	if !ok {
+		return nil, fmt.Errorf("поле date обязательно и должно быть строкой YYYY-MM-DD")
+	}
+	date, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return nil, fmt.Errorf("используйте YYYY-MM-DD, неверный формат даты, ошибка: %v", err.Error())
+	}
+	// в документе только день: время суток и зона хранимой даты сохраняются, и при переносе на другой день
+	hour, minute, second := current.Date.Clock()
+	date = time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, current.Date.Nanosecond(), current.Date.Location())
+
+	title, ok := doc["title"].(string)
+	if !ok || title == "" {
+		return nil, fmt.Errorf("title не может быть пустым")
+	}
+
+	content := ""
+	if value, ok := doc["content"]; ok {
+		if content, ok = value.(string); !ok {
+			return nil, fmt.Errorf("поле content должно быть строкой")
+		}
+	}
+
+	return &storage.Event{
+		ID:      current.ID,
+		UserID:  current.UserID,
+		Date:    date,
+		Title:   title,
+		Content: content,
+	}, nil
+}
+
+// mergePatch реализует алгоритм MergePatch из RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+
+	return targetObj
+}
+
+// patchOperation - одна операция JSON Patch (RFC 6902)
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// value разбирает поле value операции
+func (op patchOperation) value() (interface{}, error) {
+
+	if op.Value == nil {
+		return nil, fmt.Errorf("не задано поле value")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(*op.Value, &value); err != nil {
+		return nil, fmt.Errorf("неверное поле value: %w", err)
+	}
+
+	return value, nil
+}
+
+// apply выполняет операцию над документом и возвращает новый документ
+func (op patchOperation) apply(doc interface{}) (interface{}, error) {
+
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, value)
+
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pointerGet(doc, op.Path); err != nil {
+			return nil, err
+		}
+		if doc, _, err = pointerRemove(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, value)
+
+	case "move":
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("нельзя переместить значение внутрь самого себя")
+		}
+		doc, value, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, value)
+
+	case "copy":
+		value, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, deepCopy(value))
+
+	case "test":
+		expected, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(actual, expected) {
+			return nil, errPatchTestFailed
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("неизвестная операция %q", op.Op)
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901) на токены
+func parsePointer(pointer string) ([]string, error) {
+
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("путь %q должен начинаться с /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex разбирает индекс массива (allowEnd - разрешён "-" и индекс, равный длине)
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("неверный индекс массива %q", token)
+	}
+	if idx > length || (!allowEnd && idx == length) {
+		return 0, fmt.Errorf("индекс %d за пределами массива", idx)
+	}
+
+	return idx, nil
+}
+
+// pointerGet возвращает значение по пути
+func pointerGet(doc interface{}, pointer string) (interface{}, error) {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("путь %q не найден", pointer)
+			}
+			current = value
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("путь %q не найден", pointer)
+		}
+	}
+
+	return current, nil
+}
+
+// pointerAdd добавляет значение по пути и возвращает новый документ
+func pointerAdd(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil // замена документа целиком
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		idx, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[idx+1:], node[idx:])
+		node[idx] = value
+		return pointerReplaceNode(doc, parentPointer, node)
+	}
+
+	return nil, fmt.Errorf("путь %q не найден", pointer)
+}
+
+// pointerRemove удаляет значение по пути, возвращает новый документ и удалённое значение
+func pointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("нельзя удалить документ целиком")
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("путь %q не найден", pointer)
+		}
+		delete(node, last)
+		return doc, value, nil
+	case []interface{}:
+		idx, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[idx]
+		node = append(node[:idx:idx], node[idx+1:]...)
+		doc, err = pointerReplaceNode(doc, parentPointer, node)
+		return doc, value, err
+	}
+
+	return nil, nil, fmt.Errorf("путь %q не найден", pointer)
+}
+
+// pointerReplaceNode подменяет узел по пути (нужно для массивов, которые меняют длину)
+func pointerReplaceNode(doc interface{}, pointer string, node interface{}) (interface{}, error) {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return node, nil
+	}
+
+	parent, err := pointerGet(doc, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = node
+	case []interface{}:
+		idx, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p[idx] = node
+	}
+
+	return doc, nil
+}
+
+// deepCopy копирует значение, разобранное из JSON
+func deepCopy(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = deepCopy(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item)
+		}
+		return result
+	}
+
+	return value
+}
+
+// jsonEqual сравнивает два значения, разобранных из JSON
+func jsonEqual(a, b interface{}) bool {
+
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(left, right)
+}
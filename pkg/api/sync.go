@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// GET /sync?user_id=123&token=MTIzOjQy
+// SyncHandler обрабатывает запрос на инкрементальную синхронизацию
+// (без token - полный снимок; в ответе новый sync_token для следующего запроса;
+// 410 - токен устарел, нужно синхронизироваться заново без token)
+func (api *API) SyncHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	// парсим query параметры
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный user_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	// вызываем storage
+	result, err := api.repo().SyncContext(r.Context(), userID, r.URL.Query().Get("token"))
+	if err != nil {
+		answer.Error = err.Error()
+		switch {
+		case errors.Is(err, storage.ErrInvalidSyncToken):
+			WriterJSON(w, http.StatusBadRequest, answer) // 400
+		case errors.Is(err, storage.ErrSyncTokenExpired):
+			WriterJSON(w, http.StatusGone, answer) // 410
+		default:
+			WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		}
+		return
+	}
+
+	answer.Result = result
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
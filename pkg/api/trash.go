@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// GET /trash?user_id=123
+// GetTrashHandler обрабатывает запрос на чтение корзины пользователя
+func (api *API) GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	// парсим query параметры
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный user_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	// вызываем storage
+	items, err := api.repo().TrashContext(r.Context(), userID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		return
+	}
+
+	answer.Result = items
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+/*
+POST /restore_event
+{
+  "user_id": 123,
+  "event_id": 5
+}
+*/
+// RestoreEventHandler обрабатывает запрос на восстановление события из корзины
+func (api *API) RestoreEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	// структура для парсинга запроса
+	var req struct {
+		UserID  int `json:"user_id"`
+		EventID int `json:"event_id"`
+	}
+
+	// читаем запрос
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	// определяем структуру
+	err = json.Unmarshal(buf.Bytes(), &req)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно десериализовать тело запроса %v", err.Error())
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// проверка обязательных полей
+	if req.EventID <= 0 {
+		answer.Error = "ID события должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+	if req.UserID <= 0 {
+		answer.Error = "user_id должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// вызываем storage
+	event, err := api.repo().RestoreContext(r.Context(), req.UserID, req.EventID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, storageErrorStatus(err), answer) // 429 / 503
+		return
+	}
+
+	answer.Result = event
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+/*
+POST /purge_event
+{
+  "user_id": 123,
+  "event_id": 5
+}
+*/
+// PurgeEventHandler обрабатывает запрос на окончательное удаление события из корзины
+func (api *API) PurgeEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	// структура для парсинга запроса
+	var req struct {
+		UserID  int `json:"user_id"`
+		EventID int `json:"event_id"`
+	}
+
+	// читаем запрос
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	// определяем структуру
+	err = json.Unmarshal(buf.Bytes(), &req)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно десериализовать тело запроса %v", err.Error())
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// проверка обязательных полей
+	if req.EventID <= 0 {
+		answer.Error = "ID события должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+	if req.UserID <= 0 {
+		answer.Error = "user_id должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// вызываем storage
+	if err := api.repo().PurgeContext(r.Context(), req.UserID, req.EventID); err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		return
+	}
+
+	answer.Result = "событие удалено навсегда"
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
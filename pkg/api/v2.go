@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// v2 - ресурсный API: ID в пути, глаголы HTTP вместо имён действий, в ответе сам ресурс.
+// Ошибки отдаются как {"error": "..."} (та же структура Answer)
+
+// v2EventBody - тело POST и PUT для события
+type v2EventBody struct {
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Content string `json:"content,omitempty"`
+	Version int    `json:"version,omitempty"` // для PUT можно вместо If-Match
+}
+
+// v2Error отправляет ошибку в формате v2
+func v2Error(w http.ResponseWriter, status int, message string) {
+	WriterJSON(w, status, Answer{Error: message})
+}
+
+// v2StorageStatus подбирает HTTP-статус для ошибки хранилища
+func v2StorageStatus(err error) int {
+
+	if errors.Is(err, storage.ErrNotFound) {
+		return http.StatusNotFound // 404
+	}
+
+	return storageErrorStatus(err) // 412 / 413 / 429 / 503
+}
+
+// v2UserID достаёт ID пользователя из пути
+func v2UserID(r *http.Request) (int, error) {
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		return 0, fmt.Errorf("неверный id пользователя")
+	}
+
+	return userID, nil
+}
+
+// v2EventIDs достаёт ID пользователя и события из пути
+func v2EventIDs(r *http.Request) (int, int, error) {
+
+	userID, err := v2UserID(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	eventID, err := strconv.Atoi(r.PathValue("eventID"))
+	if err != nil || eventID <= 0 {
+		return 0, 0, fmt.Errorf("неверный id события")
+	}
+
+	return userID, eventID, nil
+}
+
+// v2EventLocation возвращает адрес ресурса события (с префиксом маршрутов экземпляра)
+func (api *API) v2EventLocation(event *storage.Event) string {
+	return fmt.Sprintf("%s/v2/users/%d/events/%d", api.prefix, event.UserID, event.ID)
+}
+
+// readV2EventBody читает и валидирует тело POST/PUT
+func readV2EventBody(r *http.Request) (*v2EventBody, time.Time, error) {
+
+	var buf bytes.Buffer
+	var body v2EventBody
+
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		return nil, time.Time{}, fmt.Errorf("невозможно прочитать тело запроса %w", err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &body); err != nil {
+		return nil, time.Time{}, fmt.Errorf("невозможно десериализовать тело запроса %v", err.Error())
+	}
+
+	date, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("используйте YYYY-MM-DD, неверный формат даты, ошибка: %v", err.Error())
+	}
+	if body.Title == "" {
+		return nil, time.Time{}, fmt.Errorf("поле title должно быть заполнено")
+	}
+
+	return &body, date, nil
+}
+
+// GET /v2/users/{id}/events?date=2026-01-15&period=day|week|month (по умолчанию month)
+// V2ListEventsHandler возвращает события пользователя за период
+func (api *API) V2ListEventsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := v2UserID(r)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, "параметр date обязателен (используйте YYYY-MM-DD)")
+		return
+	}
+
+	var events []*storage.Event
+	switch period := r.URL.Query().Get("period"); period {
+	case "day":
+		events, err = api.repo().GetForDayContext(r.Context(), userID, date)
+	case "week":
+		events, err = api.repo().GetForWeekContext(r.Context(), userID, date)
+	case "month", "":
+		events, err = api.repo().GetForMonthContext(r.Context(), userID, date)
+	default:
+		v2Error(w, http.StatusBadRequest, fmt.Sprintf("неизвестный period %q, используйте day, week или month", period))
+		return
+	}
+
+	// у пользователя без событий просто пустой список
+	if errors.Is(err, storage.ErrNotFound) {
+		events, err = []*storage.Event{}, nil
+	}
+	if err != nil {
+		v2Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	WriterJSON(w, http.StatusOK, events) // 200
+}
+
+// POST /v2/users/{id}/events
+// V2CreateEventHandler создаёт событие, возвращает его с Location и ETag
+func (api *API) V2CreateEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := v2UserID(r)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body, date, err := readV2EventBody(r)
+	if err != nil {
+		v2Error(w, bodyStatus(err), err.Error())
+		return
+	}
+
+	id, err := api.repo().CreateContext(r.Context(), userID, date, body.Title, body.Content)
+	if err != nil {
+		v2Error(w, storageErrorStatus(err), err.Error())
+		return
+	}
+
+	event, err := api.repo().GetContext(r.Context(), userID, id)
+	if err != nil {
+		v2Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", api.v2EventLocation(event))
+	w.Header().Set("ETag", etag(event.Version))
+
+	WriterJSON(w, http.StatusCreated, event) // 201
+}
+
+// GET /v2/users/{id}/events/{eventID}
+// V2GetEventHandler возвращает событие с ETag
+func (api *API) V2GetEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, eventID, err := v2EventIDs(r)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	event, err := api.repo().GetContext(r.Context(), userID, eventID)
+	if err != nil {
+		v2Error(w, v2StorageStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", etag(event.Version))
+	if r.Header.Get("If-None-Match") == etag(event.Version) {
+		w.WriteHeader(http.StatusNotModified) // 304
+		return
+	}
+
+	WriterJSON(w, http.StatusOK, event) // 200
+}
+
+// PUT /v2/users/{id}/events/{eventID} (If-Match или поле version обязательны)
+// V2ReplaceEventHandler заменяет событие целиком (не переданный content очищается)
+func (api *API) V2ReplaceEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, eventID, err := v2EventIDs(r)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body, date, err := readV2EventBody(r)
+	if err != nil {
+		v2Error(w, bodyStatus(err), err.Error())
+		return
+	}
+
+	version, err := expectedVersion(r, body.Version)
+	if err != nil {
+		v2Error(w, versionErrorStatus(err), err.Error())
+		return
+	}
+
+	actorID, err := actorFromRequest(r, userID)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// хранилище записывает в event сохранённое состояние - ответ собираем из него
+	event := &storage.Event{
+		ID:      eventID,
+		UserID:  userID,
+		Date:    date,
+		Title:   body.Title,
+		Content: body.Content,
+		Version: version,
+	}
+	if err := api.repo().UpdateByContext(r.Context(), actorID, event); err != nil {
+		v2Error(w, v2StorageStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", etag(event.Version))
+
+	WriterJSON(w, http.StatusOK, event) // 200
+}
+
+// PATCH /v2/users/{id}/events/{eventID} (merge patch или JSON Patch, If-Match обязателен)
+// V2PatchEventHandler частично обновляет событие
+func (api *API) V2PatchEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	var buf bytes.Buffer
+
+	userID, eventID, err := v2EventIDs(r)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		v2Error(w, bodyStatus(err), fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error()))
+		return
+	}
+
+	event, status, err := api.patchEvent(r, userID, eventID, buf.Bytes())
+	if err != nil {
+		if status == http.StatusServiceUnavailable {
+			status = v2StorageStatus(err)
+		}
+		v2Error(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", etag(event.Version))
+
+	WriterJSON(w, http.StatusOK, event) // 200
+}
+
+// DELETE /v2/users/{id}/events/{eventID} (If-Match обязателен)
+// V2DeleteEventHandler перемещает событие в корзину
+func (api *API) V2DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, eventID, err := v2EventIDs(r)
+	if err != nil {
+		v2Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, err := expectedVersion(r, 0)
+	if err != nil {
+		v2Error(w, versionErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := api.repo().DeleteIfMatchContext(r.Context(), userID, eventID, version); err != nil {
+		v2Error(w, v2StorageStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent) // 204
+}
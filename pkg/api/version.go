@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// errPreconditionRequired - клиент не сообщил, какую версию события он изменяет
+var errPreconditionRequired = errors.New("укажите версию события в заголовке If-Match или в поле version")
+
+// etag формирует значение заголовка ETag для версии события
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// expectedVersion возвращает версию, которую клиент ожидает изменить:
+// из If-Match ("3", W/"3" или * - без проверки) или из поля version тела запроса
+func expectedVersion(r *http.Request, bodyVersion int) (int, error) {
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		if bodyVersion <= 0 {
+			return 0, errPreconditionRequired
+		}
+		return bodyVersion, nil
+	}
+
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`))
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("неверный заголовок If-Match: %q", ifMatch)
+	}
+	if bodyVersion != 0 && bodyVersion != version {
+		return 0, fmt.Errorf("версия в If-Match (%d) и в поле version (%d) не совпадают", version, bodyVersion)
+	}
+
+	return version, nil
+}
+
+// versionErrorStatus подбирает HTTP-статус для ошибки разбора версии
+func versionErrorStatus(err error) int {
+
+	if errors.Is(err, errPreconditionRequired) {
+		return http.StatusPreconditionRequired // 428
+	}
+
+	return http.StatusBadRequest // 400
+}
+
+// storageErrorStatus подбирает HTTP-статус для ошибки хранилища
+func storageErrorStatus(err error) int {
+
+	switch {
+	case errors.Is(err, storage.ErrVersionConflict):
+		return http.StatusPreconditionFailed // 412
+	case errors.Is(err, storage.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge // 413
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return http.StatusTooManyRequests // 429
+	}
+
+	return http.StatusServiceUnavailable // 503
+}
+
+// GET /event?user_id=123&event_id=5
+// GetEventHandler обрабатывает запрос на чтение одного события (версия - в заголовке ETag)
+func (api *API) GetEventHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	// парсим query параметры
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный user_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+	eventID, err := strconv.Atoi(r.URL.Query().Get("event_id"))
+	if err != nil || eventID <= 0 {
+		answer.Error = "неверный event_id"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	// вызываем storage
+	event, err := api.repo().GetContext(r.Context(), userID, eventID)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusNotFound, answer) // 404
+		return
+	}
+
+	w.Header().Set("ETag", etag(event.Version))
+
+	// клиент уже знает эту версию
+	if r.Header.Get("If-None-Match") == etag(event.Version) {
+		w.WriteHeader(http.StatusNotModified) // 304
+		return
+	}
+
+	answer.Result = event
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
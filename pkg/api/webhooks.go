@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/IPampurin/calendar-server/pkg/webhook"
+)
+
+/* POST /create_webhook
+Content-Type: application/json
+{
+  "url": "https://example.com/hook",
+  "secret": "необязательно, иначе сгенерируется",
+  "event_types": ["event.created", "event.deleted"],
+  "user_id": 123
+}
+*/
+// CreateWebhookHandler обрабатывает запрос на создание подписки
+func (api *API) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	if api.Webhooks == nil {
+		answer.Error = "вебхуки не настроены"
+		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		return
+	}
+
+	// req структура для парсинга параметров запроса
+	var req struct {
+		URL        string               `json:"url"`
+		Secret     string               `json:"secret,omitempty"`
+		EventTypes []storage.ChangeType `json:"event_types,omitempty"`
+		UserID     int                  `json:"user_id,omitempty"`
+	}
+
+	// читаем запрос
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	// определяем структуру
+	err = json.Unmarshal(buf.Bytes(), &req)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно десериализовать тело запроса %v", err.Error())
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// валидация внутри диспетчера
+	sub, err := api.Webhooks.AddSubscription(webhook.Subscription{
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		UserID:     req.UserID,
+	})
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	// секрет отдаём только здесь: в списке подписок его уже не будет
+	answer.Result = webhook.CreatedSubscription{Subscription: *sub, Secret: sub.Secret}
+
+	WriterJSON(w, http.StatusCreated, answer) // 201
+}
+
+/*
+POST /delete_webhook
+{
+  "id": 1
+}
+*/
+// DeleteWebhookHandler обрабатывает запрос на удаление подписки
+func (api *API) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+	var buf bytes.Buffer
+
+	if api.Webhooks == nil {
+		answer.Error = "вебхуки не настроены"
+		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		return
+	}
+
+	// структура для парсинга запроса
+	var req struct {
+		ID int `json:"id"`
+	}
+
+	// читаем запрос
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно прочитать тело запроса %v", err.Error())
+		WriterJSON(w, bodyStatus(err), answer) // 400 или 413
+		return
+	}
+
+	// определяем структуру
+	err = json.Unmarshal(buf.Bytes(), &req)
+	if err != nil {
+		answer.Error = fmt.Sprintf("невозможно десериализовать тело запроса %v", err.Error())
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	if req.ID <= 0 {
+		answer.Error = "ID подписки должен быть положительным числом"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	if err := api.Webhooks.RemoveSubscription(req.ID); err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, webhookErrorStatus(err), answer)
+		return
+	}
+
+	answer.Result = "подписка удалена"
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+// GET /webhooks?user_id=123
+// GetWebhooksHandler обрабатывает запрос на чтение подписок пользователя
+// (user_id обязателен: без него ответ раскрыл бы подписки всех пользователей)
+func (api *API) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	if api.Webhooks == nil {
+		answer.Error = "вебхуки не настроены"
+		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		return
+	}
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		answer.Error = "неверный или отсутствующий user_id"
+		WriterJSON(w, http.StatusBadRequest, answer) // 400
+		return
+	}
+
+	answer.Result = api.Webhooks.Subscriptions(userID)
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+// GET /webhook_deliveries?id=1
+// GetWebhookDeliveriesHandler обрабатывает запрос на чтение истории доставок подписки
+func (api *API) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	var answer Answer
+
+	if api.Webhooks == nil {
+		answer.Error = "вебхуки не настроены"
+		WriterJSON(w, http.StatusServiceUnavailable, answer) // 503
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id <= 0 {
+		answer.Error = "неверный id подписки"
+		WriterJSON(w, http.StatusBadRequest, answer)
+		return
+	}
+
+	deliveries, err := api.Webhooks.Deliveries(id)
+	if err != nil {
+		answer.Error = err.Error()
+		WriterJSON(w, webhookErrorStatus(err), answer)
+		return
+	}
+
+	answer.Result = deliveries
+
+	WriterJSON(w, http.StatusOK, answer) // 200
+}
+
+// webhookErrorStatus подбирает HTTP-статус для ошибки диспетчера
+func webhookErrorStatus(err error) int {
+
+	if errors.Is(err, webhook.ErrNotFound) {
+		return http.StatusNotFound // 404
+	}
+
+	return http.StatusServiceUnavailable // 503
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	retriesDefault    = 2                      // сколько раз повторять запрос после первой попытки
+	backoffDefault    = 200 * time.Millisecond // пауза перед первым повтором (дальше удваивается)
+	backoffMaxDefault = 5 * time.Second
+)
+
+// Client - типизированный клиент HTTP API календаря (ресурсный API v2)
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	header     http.Header // заголовки, которые добавляются к каждому запросу (авторизация и т.п.)
+	retries    int
+	backoff    time.Duration
+	backoffMax time.Duration
+}
+
+// Option настраивает клиента
+type Option func(*Client)
+
+// WithHTTPClient задаёт свой http.Client (таймауты, транспорт, TLS)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken добавляет к запросам заголовок Authorization: Bearer <token>
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithHeader добавляет к запросам произвольный заголовок
+func WithHeader(name, value string) Option {
+	return func(c *Client) {
+		c.header.Add(name, value)
+	}
+}
+
+// WithRetries задаёт число повторов при сетевых ошибках и ответах 429/5xx
+// и паузу перед первым повтором (0 - без повторов)
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New создаёт клиента для сервера по адресу baseURL (например, http://localhost:8081)
+func New(baseURL string, opts ...Option) (*Client, error) {
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес сервера: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("неверный адрес сервера %q: нужна схема http или https", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		header:     make(http.Header),
+		retries:    retriesDefault,
+		backoff:    backoffDefault,
+		backoffMax: backoffMaxDefault,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// errorBody - ошибка в ответе v2 ({"error": "..."})
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// do отправляет запрос с повторами и разбирает ответ в out (out может быть nil)
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, header http.Header, out interface{}) error {
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("невозможно сериализовать запрос: %w", err)
+		}
+	}
+	// повтор изменения не должен выполниться дважды - сервер узнает запрос по ключу и вернёт первый ответ
+	if method != http.MethodGet && header.Get("Idempotency-Key") == "" {
+		header = header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("Idempotency-Key", newIdempotencyKey())
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+
+		resp, err := c.send(ctx, method, u.String(), payload, header)
+		if err == nil {
+			err = decodeResponse(resp, out)
+		}
+
+		if err == nil || attempt >= c.retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, c.backoffMax)
+	}
+}
+
+// send выполняет одну попытку запроса
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, header http.Header) (*http.Response, error) {
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// trace-context из ctx уходит в traceparent (если приложение настроило OpenTelemetry)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return c.httpClient.Do(req)
+}
+
+// decodeResponse разбирает ответ сервера, ошибки возвращает как *APIError
+func decodeResponse(resp *http.Response, out interface{}) error {
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e errorBody
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			message = e.Error
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("невозможно разобрать ответ сервера: %w", err)
+	}
+
+	return nil
+}
+
+// retryable решает, имеет ли смысл повторить запрос
+func retryable(err error) bool {
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	// сетевые ошибки
+	return true
+}
+
+// newIdempotencyKey генерирует случайный ключ для Idempotency-Key
+func newIdempotencyKey() string {
+
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrBadRequest - сервер отклонил запрос как неверный (400)
+	ErrBadRequest = errors.New("неверный запрос")
+	// ErrNotFound - событие или пользователь не найдены (404)
+	ErrNotFound = errors.New("не найдено")
+	// ErrVersionConflict - событие успели изменить, нужно перечитать его (412)
+	ErrVersionConflict = errors.New("версия события не совпадает")
+	// ErrVersionRequired - не передана версия изменяемого события (428)
+	ErrVersionRequired = errors.New("не указана версия события")
+	// ErrUnavailable - сервер или хранилище временно недоступны (5xx)
+	ErrUnavailable = errors.New("сервис недоступен")
+)
+
+// APIError - ошибка, которую вернул сервер (поле error из ответа)
+type APIError struct {
+	StatusCode int    // HTTP-статус ответа
+	Message    string // текст ошибки от сервера
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("calendar: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, client.ErrNotFound) и т.п.
+func (e *APIError) Is(target error) bool {
+
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrVersionConflict:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrVersionRequired:
+		return e.StatusCode == http.StatusPreconditionRequired
+	case ErrUnavailable:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
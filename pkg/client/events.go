@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+const dateLayout = "2006-01-02"
+
+// Event - событие календаря (та же структура, что отдаёт сервер)
+type Event = storage.Event
+
+// eventBody - тело POST и PUT события в v2
+type eventBody struct {
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Content string `json:"content,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// eventsPath - адрес событий пользователя в v2
+func eventsPath(userID int) string {
+	return fmt.Sprintf("/v2/users/%d/events", userID)
+}
+
+// eventPath - адрес одного события в v2
+func eventPath(userID, eventID int) string {
+	return fmt.Sprintf("/v2/users/%d/events/%d", userID, eventID)
+}
+
+// Create создаёт событие и возвращает его в том виде, в каком его сохранил сервер
+func (c *Client) Create(ctx context.Context, userID int, date time.Time, title, content string) (*Event, error) {
+
+	body := eventBody{Date: date.Format(dateLayout), Title: title, Content: content}
+
+	var event Event
+	if err := c.do(ctx, http.MethodPost, eventsPath(userID), nil, body, nil, &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Update сохраняет изменения события (event.Version - версия, которую клиент изменяет)
+// и возвращает новую версию; если событие успели изменить, вернёт ErrVersionConflict
+func (c *Client) Update(ctx context.Context, event *Event) (*Event, error) {
+
+	if event == nil {
+		return nil, fmt.Errorf("событие не может быть nil")
+	}
+
+	body := eventBody{Date: event.Date.Format(dateLayout), Title: event.Title, Content: event.Content, Version: event.Version}
+
+	var updated Event
+	if err := c.do(ctx, http.MethodPut, eventPath(event.UserID, event.ID), nil, body, nil, &updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// Delete перемещает событие в корзину, если его версия всё ещё равна version
+func (c *Client) Delete(ctx context.Context, userID, eventID, version int) error {
+
+	var header http.Header
+	if version > 0 {
+		header = http.Header{"If-Match": {strconv.Quote(strconv.Itoa(version))}}
+	}
+
+	return c.do(ctx, http.MethodDelete, eventPath(userID, eventID), nil, nil, header, nil)
+}
+
+// Get возвращает одно событие
+func (c *Client) Get(ctx context.Context, userID, eventID int) (*Event, error) {
+
+	var event Event
+	if err := c.do(ctx, http.MethodGet, eventPath(userID, eventID), nil, nil, nil, &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// EventsForDay возвращает события пользователя на день date
+func (c *Client) EventsForDay(ctx context.Context, userID int, date time.Time) ([]*Event, error) {
+	return c.events(ctx, "day", userID, date)
+}
+
+// EventsForWeek возвращает события пользователя на неделю (с понедельника), в которую попадает date
+func (c *Client) EventsForWeek(ctx context.Context, userID int, date time.Time) ([]*Event, error) {
+	return c.events(ctx, "week", userID, date)
+}
+
+// EventsForMonth возвращает события пользователя на месяц, в который попадает date
+func (c *Client) EventsForMonth(ctx context.Context, userID int, date time.Time) ([]*Event, error) {
+	return c.events(ctx, "month", userID, date)
+}
+
+// events - общая часть выборок по периоду (у пользователя без событий - пустой список)
+func (c *Client) events(ctx context.Context, period string, userID int, date time.Time) ([]*Event, error) {
+
+	query := url.Values{
+		"period": {period},
+		"date":   {date.Format(dateLayout)},
+	}
+
+	events := make([]*Event, 0)
+	if err := c.do(ctx, http.MethodGet, eventsPath(userID), query, nil, nil, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// envFileDefault - файл переменных окружения, который читается, если он есть
+const envFileDefault = ".env"
+
+// Config - все настройки сервера
+// (источники по возрастанию приоритета: значения по умолчанию, файл YAML/TOML, .env, окружение, флаги)
+type Config struct {
+	Server  ServerConfig  `yaml:"server" toml:"server"`
+	Log     LogConfig     `yaml:"log" toml:"log"`
+	Trace   TraceConfig   `yaml:"trace" toml:"trace"`
+	Storage StorageConfig `yaml:"storage" toml:"storage"`
+}
+
+// ServerConfig - настройки HTTP и gRPC серверов
+type ServerConfig struct {
+	Port               string          `yaml:"port" toml:"port"`                                 // порт HTTP
+	GRPCPort           string          `yaml:"grpc_port" toml:"grpc_port"`                       // порт gRPC ("" или off - не запускать)
+	WebhookOutbox      string          `yaml:"webhook_outbox" toml:"webhook_outbox"`             // файл подписок и outbox вебхуков
+	IdempotencyWindow  time.Duration   `yaml:"idempotency_window" toml:"idempotency_window"`     // сколько хранить ответы для Idempotency-Key
+	ShutdownTimeout    time.Duration   `yaml:"shutdown_timeout" toml:"shutdown_timeout"`         // сколько ждать завершения запросов при остановке
+	DrainDelay         time.Duration   `yaml:"drain_delay" toml:"drain_delay"`                   // сколько /readyz отвечает 503 до закрытия порта
+	TrashPurgeInterval time.Duration   `yaml:"trash_purge_interval" toml:"trash_purge_interval"` // как часто чистить корзину
+	ReadHeaderTimeout  time.Duration   `yaml:"read_header_timeout" toml:"read_header_timeout"`   // сколько ждать заголовки запроса
+	ReadTimeout        time.Duration   `yaml:"read_timeout" toml:"read_timeout"`                 // сколько ждать запрос целиком
+	WriteTimeout       time.Duration   `yaml:"write_timeout" toml:"write_timeout"`               // сколько можно писать ответ
+	IdleTimeout        time.Duration   `yaml:"idle_timeout" toml:"idle_timeout"`                 // сколько держать простаивающее соединение
+	MaxBodyBytes       int64           `yaml:"max_body_bytes" toml:"max_body_bytes"`             // наибольший размер тела запроса (больше - 413)
+	H2C                bool            `yaml:"h2c" toml:"h2c"`                                   // HTTP/2 без TLS для прокси перед сервером
+	TLS                TLSConfig       `yaml:"tls" toml:"tls"`                                   // HTTPS и gRPC по TLS
+	RateLimit          RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`                     // ограничение частоты запросов к API
+}
+
+// RateLimitConfig - ограничение частоты запросов (token bucket; rate 0 - без ограничения)
+type RateLimitConfig struct {
+	UserRate   float64 `yaml:"user_rate" toml:"user_rate"`     // запросов в секунду на пользователя
+	UserBurst  int     `yaml:"user_burst" toml:"user_burst"`   // сколько запросов пользователь может сделать подряд
+	IPRate     float64 `yaml:"ip_rate" toml:"ip_rate"`         // запросов в секунду на IP клиента
+	IPBurst    int     `yaml:"ip_burst" toml:"ip_burst"`       // сколько запросов с одного IP можно сделать подряд
+	TrustProxy bool    `yaml:"trust_proxy" toml:"trust_proxy"` // брать IP из X-Forwarded-For (только за своим прокси)
+}
+
+// TLSConfig - настройки TLS (TLS включается, если задан cert_file)
+type TLSConfig struct {
+	CertFile       string         `yaml:"cert_file" toml:"cert_file"`             // сертификат сервера (PEM)
+	KeyFile        string         `yaml:"key_file" toml:"key_file"`               // ключ сервера (PEM)
+	ClientCAFile   string         `yaml:"client_ca_file" toml:"client_ca_file"`   // CA для проверки сертификатов клиентов (mTLS)
+	ClientAuth     string         `yaml:"client_auth" toml:"client_auth"`         // none, request (проверять, если есть) или require
+	ClientUsers    map[string]int `yaml:"client_users" toml:"client_users"`       // CN сертификата клиента -> ID пользователя
+	ReloadInterval time.Duration  `yaml:"reload_interval" toml:"reload_interval"` // как часто проверять, не сменились ли файлы
+}
+
+// LogConfig - настройки журнала
+type LogConfig struct {
+	Dir       string        `yaml:"dir" toml:"dir"`                 // папка с файлами логов
+	Format    string        `yaml:"format" toml:"format"`           // text или json
+	Level     string        `yaml:"level" toml:"level"`             // debug, info, warn или error
+	MaxSizeMB int           `yaml:"max_size_mb" toml:"max_size_mb"` // размер файла, после которого начинается новый (0 - только по дням)
+	MaxFiles  int           `yaml:"max_files" toml:"max_files"`     // сколько старых файлов хранить (0 - все)
+	MaxAge    time.Duration `yaml:"max_age" toml:"max_age"`         // сколько хранить старые файлы (0 - не удалять по возрасту)
+	Compress  bool          `yaml:"compress" toml:"compress"`       // сжимать старые файлы в gzip
+}
+
+// TraceConfig - настройки трассировки OpenTelemetry
+type TraceConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`         // none, stdout, otlp или otlphttp
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`         // адрес коллектора ("" - по умолчанию экспортёра)
+	ServiceName string  `yaml:"service_name" toml:"service_name"` // service.name в ресурсе
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"` // доля трассируемых запросов (0 - ни одного, 1 - все)
+}
+
+// StorageConfig - настройки хранилища
+type StorageConfig struct {
+	ChangeLogLimit   int           `yaml:"change_log_limit" toml:"change_log_limit"`       // сколько изменений хранить на пользователя для синхронизации
+	TrashRetention   time.Duration `yaml:"trash_retention" toml:"trash_retention"`         // сколько удалённые события лежат в корзине
+	MaxEventsPerUser int           `yaml:"max_events_per_user" toml:"max_events_per_user"` // сколько событий может быть у пользователя (0 - без ограничения)
+	MaxTitleLength   int           `yaml:"max_title_length" toml:"max_title_length"`       // наибольшая длина title в символах (0 - без ограничения)
+	MaxContentLength int           `yaml:"max_content_length" toml:"max_content_length"`   // наибольшая длина content в символах (0 - без ограничения)
+}
+
+// Default возвращает настройки по умолчанию
+func Default() Config {
+
+	return Config{
+		Server: ServerConfig{
+			Port:               "8081",
+			GRPCPort:           "9091",
+			WebhookOutbox:      "data/webhooks.json",
+			IdempotencyWindow:  24 * time.Hour,
+			ShutdownTimeout:    30 * time.Second,
+			DrainDelay:         5 * time.Second,
+			TrashPurgeInterval: time.Hour,
+			ReadHeaderTimeout:  5 * time.Second,
+			ReadTimeout:        30 * time.Second,
+			WriteTimeout:       60 * time.Second,
+			IdleTimeout:        120 * time.Second,
+			MaxBodyBytes:       1 << 20,
+			TLS: TLSConfig{
+				ClientAuth:     "none",
+				ReloadInterval: 30 * time.Second,
+			},
+			RateLimit: RateLimitConfig{
+				UserRate:  20,
+				UserBurst: 40,
+				IPRate:    50,
+				IPBurst:   100,
+			},
+		},
+		Log: LogConfig{
+			Dir:      "logs",
+			Format:   "text",
+			Level:    "info",
+			MaxFiles: 30,
+			MaxAge:   90 * 24 * time.Hour,
+			Compress: true,
+		},
+		Trace: TraceConfig{
+			Exporter:    "none",
+			ServiceName: "calendar-server",
+			SampleRatio: 1,
+		},
+		Storage: StorageConfig{
+			ChangeLogLimit:   1000,
+			TrashRetention:   30 * 24 * time.Hour,
+			MaxEventsPerUser: 10000,
+			MaxTitleLength:   500,
+			MaxContentLength: 20000,
+		},
+	}
+}
+
+// Load собирает настройки из всех источников и проверяет их; args - аргументы командной строки без имени программы
+// (-config или CALENDAR_CONFIG - файл настроек, -env-file - файл переменных окружения; -h - flag.ErrHelp)
+func Load(args []string) (Config, error) {
+
+	// первый проход - только чтобы узнать, откуда читать файлы (и сразу сообщить о неверных флагах)
+	cfg := Default()
+	var configPath, envFile string
+	fs, _ := flagSet(&cfg, &configPath, &envFile)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("лишние аргументы: %s", strings.Join(fs.Args(), " "))
+	}
+
+	// .env не перекрывает переменные, уже заданные в окружении
+	if err := godotenv.Load(envFile); err != nil {
+		if envFile != envFileDefault || !errors.Is(err, os.ErrNotExist) {
+			return Config{}, fmt.Errorf("ошибка чтения %s: %w", envFile, err)
+		}
+	}
+	if configPath == "" {
+		configPath = os.Getenv("CALENDAR_CONFIG")
+	}
+
+	cfg = Default()
+	if configPath != "" {
+		if err := LoadFile(configPath, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// окружение поверх файла, флаги поверх окружения
+	fs, env := flagSet(&cfg, &configPath, &envFile)
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		name, ok := env[f.Name]
+		if !ok {
+			return
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("неверное значение %s: %q", name, value))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Server.GRPCPort == "off" {
+		cfg.Server.GRPCPort = ""
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// LoadFile читает настройки из файла .yaml, .yml или .toml поверх cfg
+// (незнакомые ключи - ошибка, чтобы опечатка не прошла молча)
+func LoadFile(path string, cfg *Config) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения файла настроек: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("ошибка разбора %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("ошибка разбора %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("ошибка разбора %s: неизвестный ключ %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("неизвестный формат файла настроек %q, используйте .yaml, .yml или .toml", path)
+	}
+
+	return nil
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки сразу
+func (c Config) Validate() error {
+
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port: неверный порт %q", c.Server.Port)
+	check(c.Server.GRPCPort == "" || validPort(c.Server.GRPCPort), "server.grpc_port: неверный порт %q", c.Server.GRPCPort)
+	check(c.Server.WebhookOutbox != "", "server.webhook_outbox: не задан файл outbox")
+	check(c.Server.IdempotencyWindow >= 0, "server.idempotency_window не может быть отрицательным")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout должен быть больше нуля")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay не может быть отрицательным")
+	check(c.Server.TrashPurgeInterval > 0, "server.trash_purge_interval должен быть больше нуля")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout должен быть больше нуля")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout должен быть больше нуля")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout должен быть больше нуля")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout должен быть больше нуля")
+	check(c.Server.MaxBodyBytes > 0, "server.max_body_bytes должен быть больше нуля")
+
+	tlsOn := c.Server.TLS.CertFile != ""
+	check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""), "server.tls: cert_file и key_file задаются вместе")
+	check(tlsOn || c.Server.TLS.ClientCAFile == "", "server.tls.client_ca_file: без cert_file TLS выключен")
+	check(!tlsOn || !c.Server.H2C, "server.h2c: HTTP/2 без TLS нельзя включить вместе с TLS")
+	check(c.Server.TLS.ReloadInterval > 0, "server.tls.reload_interval должен быть больше нуля")
+	check(c.Server.RateLimit.UserRate >= 0, "server.rate_limit.user_rate не может быть отрицательным")
+	check(c.Server.RateLimit.UserBurst >= 0, "server.rate_limit.user_burst не может быть отрицательным")
+	check(c.Server.RateLimit.IPRate >= 0, "server.rate_limit.ip_rate не может быть отрицательным")
+	check(c.Server.RateLimit.IPBurst >= 0, "server.rate_limit.ip_burst не может быть отрицательным")
+	switch c.Server.TLS.ClientAuth {
+	case "", "none":
+	case "request", "require":
+		check(c.Server.TLS.ClientCAFile != "", "server.tls.client_auth: для %s нужен client_ca_file", c.Server.TLS.ClientAuth)
+	default:
+		check(false, "server.tls.client_auth: неверное значение %q, используйте none, request или require", c.Server.TLS.ClientAuth)
+	}
+
+	check(c.Log.Dir != "", "log.dir: не задана папка логов")
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: неверный формат %q, используйте text или json", c.Log.Format)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level: неверный уровень %q, используйте debug, info, warn или error", c.Log.Level)
+	check(c.Log.MaxSizeMB >= 0, "log.max_size_mb не может быть отрицательным")
+	check(c.Log.MaxFiles >= 0, "log.max_files не может быть отрицательным")
+	check(c.Log.MaxAge >= 0, "log.max_age не может быть отрицательным")
+
+	switch strings.ToLower(c.Trace.Exporter) {
+	case "", "none", "stdout", "otlp", "otlphttp":
+	default:
+		check(false, "trace.exporter: неизвестный экспортёр %q, используйте none, stdout, otlp или otlphttp", c.Trace.Exporter)
+	}
+	check(c.Trace.SampleRatio >= 0 && c.Trace.SampleRatio <= 1, "trace.sample_ratio: нужно число от 0 до 1, получено %v", c.Trace.SampleRatio)
+
+	check(c.Storage.ChangeLogLimit >= 0, "storage.change_log_limit не может быть отрицательным")
+	check(c.Storage.TrashRetention >= 0, "storage.trash_retention не может быть отрицательным")
+	check(c.Storage.MaxEventsPerUser >= 0, "storage.max_events_per_user не может быть отрицательным")
+	check(c.Storage.MaxTitleLength >= 0, "storage.max_title_length не может быть отрицательным")
+	check(c.Storage.MaxContentLength >= 0, "storage.max_content_length не может быть отрицательным")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("неверные настройки: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// validPort проверяет, что порт - число от 0 до 65535 (0 - любой свободный)
+func validPort(port string) bool {
+
+	n, err := strconv.Atoi(port)
+
+	return err == nil && n >= 0 && n <= 65535
+}
+
+// flagSet описывает флаги командной строки поверх cfg и возвращает соответствие флаг -> переменная окружения
+func flagSet(cfg *Config, configPath, envFile *string) (*flag.FlagSet, map[string]string) {
+
+	fs := flag.NewFlagSet("calendar-server", flag.ContinueOnError)
+	env := make(map[string]string)
+
+	stringVar := func(p *string, name, envName, usage string) {
+		fs.StringVar(p, name, *p, usage)
+		env[name] = envName
+	}
+	intVar := func(p *int, name, envName, usage string) {
+		fs.IntVar(p, name, *p, usage)
+		env[name] = envName
+	}
+	boolVar := func(p *bool, name, envName, usage string) {
+		fs.BoolVar(p, name, *p, usage)
+		env[name] = envName
+	}
+	int64Var := func(p *int64, name, envName, usage string) {
+		fs.Int64Var(p, name, *p, usage)
+		env[name] = envName
+	}
+	floatVar := func(p *float64, name, envName, usage string) {
+		fs.Float64Var(p, name, *p, usage)
+		env[name] = envName
+	}
+	durationVar := func(p *time.Duration, name, envName, usage string) {
+		fs.DurationVar(p, name, *p, usage)
+		env[name] = envName
+	}
+
+	fs.StringVar(configPath, "config", "", "файл настроек .yaml или .toml (или CALENDAR_CONFIG)")
+	fs.StringVar(envFile, "env-file", envFileDefault, "файл переменных окружения")
+
+	stringVar(&cfg.Server.Port, "port", "CALENDAR_PORT", "порт HTTP")
+	stringVar(&cfg.Server.GRPCPort, "grpc-port", "CALENDAR_GRPC_PORT", "порт gRPC (off - не запускать)")
+	stringVar(&cfg.Server.WebhookOutbox, "webhook-outbox", "CALENDAR_WEBHOOK_OUTBOX", "файл подписок и outbox вебхуков")
+	durationVar(&cfg.Server.IdempotencyWindow, "idempotency-window", "CALENDAR_IDEMPOTENCY_WINDOW", "сколько хранить ответы для Idempotency-Key")
+	durationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", "CALENDAR_SHUTDOWN_TIMEOUT", "сколько ждать завершения запросов при остановке")
+	durationVar(&cfg.Server.DrainDelay, "drain-delay", "CALENDAR_DRAIN_DELAY", "сколько /readyz отвечает 503 до закрытия порта")
+	durationVar(&cfg.Server.TrashPurgeInterval, "trash-purge-interval", "CALENDAR_TRASH_PURGE_INTERVAL", "как часто чистить корзину")
+	durationVar(&cfg.Server.ReadHeaderTimeout, "read-header-timeout", "CALENDAR_READ_HEADER_TIMEOUT", "сколько ждать заголовки запроса")
+	durationVar(&cfg.Server.ReadTimeout, "read-timeout", "CALENDAR_READ_TIMEOUT", "сколько ждать запрос целиком")
+	durationVar(&cfg.Server.WriteTimeout, "write-timeout", "CALENDAR_WRITE_TIMEOUT", "сколько можно писать ответ")
+	durationVar(&cfg.Server.IdleTimeout, "idle-timeout", "CALENDAR_IDLE_TIMEOUT", "сколько держать простаивающее keep-alive соединение")
+	boolVar(&cfg.Server.H2C, "h2c", "CALENDAR_H2C", "HTTP/2 без TLS (h2c) для прокси перед сервером")
+	stringVar(&cfg.Server.TLS.CertFile, "tls-cert", "CALENDAR_TLS_CERT_FILE", "сертификат сервера (PEM), включает HTTPS")
+	stringVar(&cfg.Server.TLS.KeyFile, "tls-key", "CALENDAR_TLS_KEY_FILE", "ключ сервера (PEM)")
+	stringVar(&cfg.Server.TLS.ClientCAFile, "tls-client-ca", "CALENDAR_TLS_CLIENT_CA_FILE", "CA для проверки сертификатов клиентов (mTLS)")
+	stringVar(&cfg.Server.TLS.ClientAuth, "tls-client-auth", "CALENDAR_TLS_CLIENT_AUTH", "сертификат клиента: none, request или require")
+	durationVar(&cfg.Server.TLS.ReloadInterval, "tls-reload-interval", "CALENDAR_TLS_RELOAD_INTERVAL", "как часто проверять, не сменились ли файлы сертификатов")
+	int64Var(&cfg.Server.MaxBodyBytes, "max-body-bytes", "CALENDAR_MAX_BODY_BYTES", "наибольший размер тела запроса в байтах (больше - 413)")
+	floatVar(&cfg.Server.RateLimit.UserRate, "rate-limit-user", "CALENDAR_RATE_LIMIT_USER", "запросов в секунду на пользователя (0 - без ограничения)")
+	intVar(&cfg.Server.RateLimit.UserBurst, "rate-limit-user-burst", "CALENDAR_RATE_LIMIT_USER_BURST", "сколько запросов пользователь может сделать подряд")
+	floatVar(&cfg.Server.RateLimit.IPRate, "rate-limit-ip", "CALENDAR_RATE_LIMIT_IP", "запросов в секунду на IP клиента (0 - без ограничения)")
+	intVar(&cfg.Server.RateLimit.IPBurst, "rate-limit-ip-burst", "CALENDAR_RATE_LIMIT_IP_BURST", "сколько запросов с одного IP можно сделать подряд")
+	boolVar(&cfg.Server.RateLimit.TrustProxy, "rate-limit-trust-proxy", "CALENDAR_RATE_LIMIT_TRUST_PROXY", "брать IP клиента из X-Forwarded-For (только за своим прокси)")
+
+	stringVar(&cfg.Log.Dir, "log-dir", "CALENDAR_LOG_DIR", "папка логов")
+	stringVar(&cfg.Log.Format, "log-format", "CALENDAR_LOG_FORMAT", "формат логов: text или json")
+	stringVar(&cfg.Log.Level, "log-level", "CALENDAR_LOG_LEVEL", "уровень логов: debug, info, warn или error")
+	intVar(&cfg.Log.MaxSizeMB, "log-max-size-mb", "CALENDAR_LOG_MAX_SIZE_MB", "размер файла логов в МБ, после которого начинается новый (0 - только по дням)")
+	intVar(&cfg.Log.MaxFiles, "log-max-files", "CALENDAR_LOG_MAX_FILES", "сколько старых файлов логов хранить (0 - все)")
+	durationVar(&cfg.Log.MaxAge, "log-max-age", "CALENDAR_LOG_MAX_AGE", "сколько хранить старые файлы логов (0 - не удалять по возрасту)")
+	boolVar(&cfg.Log.Compress, "log-compress", "CALENDAR_LOG_COMPRESS", "сжимать старые файлы логов")
+
+	stringVar(&cfg.Trace.Exporter, "trace-exporter", "CALENDAR_TRACE_EXPORTER", "экспорт трассировки: none, stdout, otlp или otlphttp")
+	stringVar(&cfg.Trace.Endpoint, "trace-endpoint", "CALENDAR_TRACE_ENDPOINT", "адрес коллектора трассировки")
+	stringVar(&cfg.Trace.ServiceName, "trace-service-name", "OTEL_SERVICE_NAME", "имя сервиса в трассировке")
+	floatVar(&cfg.Trace.SampleRatio, "trace-sample-ratio", "CALENDAR_TRACE_SAMPLE_RATIO", "доля трассируемых запросов от 0 (ни одного) до 1 (все)")
+
+	intVar(&cfg.Storage.ChangeLogLimit, "change-log-limit", "CALENDAR_CHANGE_LOG_LIMIT", "сколько изменений хранить на пользователя для синхронизации")
+	durationVar(&cfg.Storage.TrashRetention, "trash-retention", "CALENDAR_TRASH_RETENTION", "сколько удалённые события лежат в корзине")
+	intVar(&cfg.Storage.MaxEventsPerUser, "max-events-per-user", "CALENDAR_MAX_EVENTS_PER_USER", "сколько событий может быть у пользователя (0 - без ограничения)")
+	intVar(&cfg.Storage.MaxTitleLength, "max-title-length", "CALENDAR_MAX_TITLE_LENGTH", "наибольшая длина title в символах (0 - без ограничения)")
+	intVar(&cfg.Storage.MaxContentLength, "max-content-length", "CALENDAR_MAX_CONTENT_LENGTH", "наибольшая длина content в символах (0 - без ограничения)")
+
+	return fs, env
+}
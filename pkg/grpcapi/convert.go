@@ -0,0 +1,109 @@
+package grpcapi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/grpcapi/calendarpb"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const dateLayout = "2006-01-02"
+
+// timestamp переводит время в Timestamp (нулевое время - nil)
+func timestamp(t time.Time) *timestamppb.Timestamp {
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
+// toEvent переводит событие хранилища в сообщение
+func toEvent(event *storage.Event) *calendarpb.Event {
+
+	if event == nil {
+		return nil
+	}
+
+	return &calendarpb.Event{
+		Id:        int64(event.ID),
+		UserId:    int64(event.UserID),
+		Date:      event.Date.Format(dateLayout),
+		Title:     event.Title,
+		Content:   event.Content,
+		Version:   int64(event.Version),
+		CreatedAt: timestamp(event.CreatedAt),
+		UpdatedAt: timestamp(event.UpdatedAt),
+	}
+}
+
+// toEvents переводит список событий
+func toEvents(events []*storage.Event) []*calendarpb.Event {
+
+	result := make([]*calendarpb.Event, 0, len(events))
+	for _, event := range events {
+		result = append(result, toEvent(event))
+	}
+
+	return result
+}
+
+// toTrashedEvent переводит событие из корзины
+func toTrashedEvent(item *storage.TrashedEvent) *calendarpb.TrashedEvent {
+
+	return &calendarpb.TrashedEvent{
+		Event:     toEvent(item.Event),
+		DeletedAt: timestamp(item.DeletedAt),
+		ExpiresAt: timestamp(item.ExpiresAt),
+	}
+}
+
+// toValue переводит значение поля из diff в google.protobuf.Value
+func toValue(v interface{}) *structpb.Value {
+
+	value, err := structpb.NewValue(v)
+	if err != nil {
+		return structpb.NewStringValue(fmt.Sprint(v))
+	}
+
+	return value
+}
+
+// toRevision переводит версию события
+func toRevision(revision *storage.Revision) *calendarpb.Revision {
+
+	diff := make([]*calendarpb.FieldChange, 0, len(revision.Diff))
+	for _, change := range revision.Diff {
+		diff = append(diff, &calendarpb.FieldChange{
+			Field: change.Field,
+			Old:   toValue(change.Old),
+			New:   toValue(change.New),
+		})
+	}
+
+	return &calendarpb.Revision{
+		Revision: int64(revision.Number),
+		EventId:  int64(revision.EventID),
+		ActorId:  int64(revision.ActorID),
+		Time:     timestamp(revision.Time),
+		Event:    toEvent(revision.Event),
+		Diff:     diff,
+	}
+}
+
+// toChange переводит изменение хранилища
+func toChange(change storage.Change) *calendarpb.Change {
+
+	return &calendarpb.Change{
+		Seq:     change.Seq,
+		Type:    string(change.Type),
+		UserId:  int64(change.UserID),
+		EventId: int64(change.EventID),
+		Event:   toEvent(change.Event),
+		Time:    timestamp(change.Time),
+	}
+}
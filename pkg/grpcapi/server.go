@@ -0,0 +1,480 @@
+package grpcapi
+
+// код calendarpb генерируется из proto/calendar/v1/calendar.proto
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/IPampurin/calendar-server --go-grpc_out=../.. --go-grpc_opt=module=github.com/IPampurin/calendar-server calendar/v1/calendar.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/grpcapi/calendarpb"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchBuffer - сколько изменений может накопиться у медленного подписчика потока
+const watchBuffer = 256
+
+// Server реализует CalendarService поверх storage.Repository
+type Server struct {
+	calendarpb.UnimplementedCalendarServiceServer
+
+	repo storage.Repository
+	db   storage.ContextRepository // repo с методами *Context: вызовы прерываются, если клиент отменил RPC
+
+	watchersMu sync.Mutex
+	watchers   map[*watcher]struct{} // открытые потоки WatchChanges
+
+	closeOnce   sync.Once
+	closed      chan struct{} // закрывается при остановке: потоки завершаются
+	unsubscribe func()        // отписка от изменений хранилища (nil - хранилище не уведомляет)
+}
+
+// watcher - один открытый поток WatchChanges
+type watcher struct {
+	userID  int
+	types   map[storage.ChangeType]bool // пусто - все типы
+	changes chan storage.Change
+	lagged  chan struct{} // закрывается, если подписчик не успевает читать
+}
+
+// NewServer создаёт gRPC-сервис; если хранилище умеет уведомлять об изменениях,
+// сервис подписывается на них для WatchChanges
+func NewServer(repo storage.Repository) *Server {
+
+	s := &Server{
+		repo:     repo,
+		db:       storage.WithContext(repo),
+		watchers: make(map[*watcher]struct{}),
+		closed:   make(chan struct{}),
+	}
+	if notifier, ok := repo.(storage.Notifier); ok {
+		s.unsubscribe = notifier.Subscribe(s.Notify)
+	}
+
+	return s
+}
+
+// Register регистрирует сервис на gRPC-сервере
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	calendarpb.RegisterCalendarServiceServer(registrar, s)
+}
+
+// Close завершает открытые потоки WatchChanges, чтобы GracefulStop не ждал их вечно,
+// и отписывает сервис от изменений хранилища
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		if s.unsubscribe != nil {
+			s.unsubscribe()
+		}
+		close(s.closed)
+	})
+}
+
+// Notify раздаёт изменение открытым потокам (storage.Listener, не блокируется)
+func (s *Server) Notify(change storage.Change) {
+
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+
+	for w := range s.watchers {
+		if w.userID != 0 && w.userID != change.UserID {
+			continue
+		}
+		if len(w.types) > 0 && !w.types[change.Type] {
+			continue
+		}
+		select {
+		case w.changes <- change:
+		default:
+			// подписчик отстал - закрываем поток, чтобы он пересинхронизировался через Sync
+			delete(s.watchers, w)
+			close(w.lagged)
+		}
+	}
+}
+
+// statusError переводит ошибку хранилища в статус gRPC
+func statusError(err error) error {
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, storage.ErrInvalidSyncToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrSyncTokenExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, storage.ErrTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// invalid - ошибка валидации запроса
+func invalid(format string, args ...interface{}) error {
+	return status.Error(codes.InvalidArgument, fmt.Sprintf(format, args...))
+}
+
+// parseDate разбирает дату YYYY-MM-DD
+func parseDate(value string) (time.Time, error) {
+
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, invalid("используйте YYYY-MM-DD, неверный формат даты %q", value)
+	}
+
+	return date, nil
+}
+
+// checkIDs проверяет, что ID положительные
+func checkIDs(userID, eventID int64) error {
+
+	if userID <= 0 {
+		return invalid("user_id должен быть положительным числом")
+	}
+	if eventID <= 0 {
+		return invalid("ID события должен быть положительным числом")
+	}
+
+	return nil
+}
+
+// checkVersion требует версию, которую клиент ожидает изменить, - как If-Match в HTTP API v2
+// (без неё изменение могло бы перезаписать чужую правку)
+func checkVersion(version int64) error {
+
+	if version <= 0 {
+		return status.Error(codes.FailedPrecondition, "укажите version - текущую версию события")
+	}
+
+	return nil
+}
+
+// actorOr возвращает автора изменения (0 - владелец события)
+func actorOr(actorID, userID int64) int {
+
+	if actorID > 0 {
+		return int(actorID)
+	}
+
+	return int(userID)
+}
+
+func (s *Server) CreateEvent(ctx context.Context, req *calendarpb.CreateEventRequest) (*calendarpb.Event, error) {
+
+	if req.GetUserId() <= 0 {
+		return nil, invalid("user_id должен быть положительным числом")
+	}
+	date, err := parseDate(req.GetDate())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetTitle() == "" {
+		return nil, invalid("поле title должно быть заполнено")
+	}
+
+	id, err := s.db.CreateContext(ctx, int(req.GetUserId()), date, req.GetTitle(), req.GetContent())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	event, err := s.db.GetContext(ctx, int(req.GetUserId()), id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toEvent(event), nil
+}
+
+func (s *Server) UpdateEvent(ctx context.Context, req *calendarpb.UpdateEventRequest) (*calendarpb.Event, error) {
+
+	in := req.GetEvent()
+	if in == nil {
+		return nil, invalid("поле event обязательно")
+	}
+	if err := checkIDs(in.GetUserId(), in.GetId()); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(in.GetVersion()); err != nil {
+		return nil, err
+	}
+	date, err := parseDate(in.GetDate())
+	if err != nil {
+		return nil, err
+	}
+	if in.GetTitle() == "" {
+		return nil, invalid("title не может быть пустым")
+	}
+
+	// хранилище записывает в event сохранённое состояние - ответ собираем из него
+	event := &storage.Event{
+		ID:      int(in.GetId()),
+		UserID:  int(in.GetUserId()),
+		Date:    date,
+		Title:   in.GetTitle(),
+		Content: in.GetContent(),
+		Version: int(in.GetVersion()),
+	}
+	if err := s.db.UpdateByContext(ctx, actorOr(req.GetActorId(), in.GetUserId()), event); err != nil {
+		return nil, statusError(err)
+	}
+
+	return toEvent(event), nil
+}
+
+func (s *Server) DeleteEvent(ctx context.Context, req *calendarpb.DeleteEventRequest) (*calendarpb.DeleteEventResponse, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(req.GetVersion()); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.DeleteIfMatchContext(ctx, int(req.GetUserId()), int(req.GetEventId()), int(req.GetVersion())); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.DeleteEventResponse{}, nil
+}
+
+func (s *Server) GetEvent(ctx context.Context, req *calendarpb.EventRef) (*calendarpb.Event, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+
+	event, err := s.db.GetContext(ctx, int(req.GetUserId()), int(req.GetEventId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toEvent(event), nil
+}
+
+func (s *Server) ListEvents(ctx context.Context, req *calendarpb.ListEventsRequest) (*calendarpb.ListEventsResponse, error) {
+
+	if req.GetUserId() <= 0 {
+		return nil, invalid("user_id должен быть положительным числом")
+	}
+	date, err := parseDate(req.GetDate())
+	if err != nil {
+		return nil, err
+	}
+
+	userID := int(req.GetUserId())
+	var events []*storage.Event
+	switch req.GetPeriod() {
+	case calendarpb.Period_PERIOD_DAY:
+		events, err = s.db.GetForDayContext(ctx, userID, date)
+	case calendarpb.Period_PERIOD_WEEK:
+		events, err = s.db.GetForWeekContext(ctx, userID, date)
+	case calendarpb.Period_PERIOD_MONTH, calendarpb.Period_PERIOD_UNSPECIFIED:
+		events, err = s.db.GetForMonthContext(ctx, userID, date)
+	default:
+		return nil, invalid("неизвестный period %v", req.GetPeriod())
+	}
+
+	// у пользователя без событий просто пустой список (как в v2)
+	if errors.Is(err, storage.ErrNotFound) {
+		events, err = nil, nil
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.ListEventsResponse{Events: toEvents(events)}, nil
+}
+
+func (s *Server) Sync(ctx context.Context, req *calendarpb.SyncRequest) (*calendarpb.SyncResponse, error) {
+
+	if req.GetUserId() <= 0 {
+		return nil, invalid("user_id должен быть положительным числом")
+	}
+
+	result, err := s.db.SyncContext(ctx, int(req.GetUserId()), req.GetToken())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	deleted := make([]int64, 0, len(result.Deleted))
+	for _, id := range result.Deleted {
+		deleted = append(deleted, int64(id))
+	}
+
+	return &calendarpb.SyncResponse{
+		Events:    toEvents(result.Events),
+		Deleted:   deleted,
+		SyncToken: result.SyncToken,
+		Full:      result.Full,
+	}, nil
+}
+
+func (s *Server) ListTrash(ctx context.Context, req *calendarpb.ListTrashRequest) (*calendarpb.ListTrashResponse, error) {
+
+	if req.GetUserId() <= 0 {
+		return nil, invalid("user_id должен быть положительным числом")
+	}
+
+	items, err := s.db.TrashContext(ctx, int(req.GetUserId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	response := &calendarpb.ListTrashResponse{Items: make([]*calendarpb.TrashedEvent, 0, len(items))}
+	for _, item := range items {
+		response.Items = append(response.Items, toTrashedEvent(item))
+	}
+
+	return response, nil
+}
+
+func (s *Server) RestoreEvent(ctx context.Context, req *calendarpb.EventRef) (*calendarpb.Event, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+
+	event, err := s.db.RestoreContext(ctx, int(req.GetUserId()), int(req.GetEventId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toEvent(event), nil
+}
+
+func (s *Server) PurgeEvent(ctx context.Context, req *calendarpb.EventRef) (*calendarpb.PurgeEventResponse, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.PurgeContext(ctx, int(req.GetUserId()), int(req.GetEventId())); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.PurgeEventResponse{}, nil
+}
+
+func (s *Server) GetHistory(ctx context.Context, req *calendarpb.EventRef) (*calendarpb.GetHistoryResponse, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.db.HistoryContext(ctx, int(req.GetUserId()), int(req.GetEventId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	response := &calendarpb.GetHistoryResponse{Revisions: make([]*calendarpb.Revision, 0, len(revisions))}
+	for _, revision := range revisions {
+		response.Revisions = append(response.Revisions, toRevision(revision))
+	}
+
+	return response, nil
+}
+
+func (s *Server) GetRevision(ctx context.Context, req *calendarpb.GetRevisionRequest) (*calendarpb.Revision, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+	if req.GetRevision() <= 0 {
+		return nil, invalid("revision должен быть положительным числом")
+	}
+
+	revision, err := s.db.RevisionContext(ctx, int(req.GetUserId()), int(req.GetEventId()), int(req.GetRevision()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toRevision(revision), nil
+}
+
+func (s *Server) RevertEvent(ctx context.Context, req *calendarpb.RevertEventRequest) (*calendarpb.Event, error) {
+
+	if err := checkIDs(req.GetUserId(), req.GetEventId()); err != nil {
+		return nil, err
+	}
+	if req.GetRevision() <= 0 {
+		return nil, invalid("revision должен быть положительным числом")
+	}
+
+	event, err := s.db.RevertContext(ctx, int(req.GetUserId()), int(req.GetEventId()), int(req.GetRevision()), actorOr(req.GetActorId(), req.GetUserId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toEvent(event), nil
+}
+
+// WatchChanges отдаёт изменения хранилища, пока клиент не отменит вызов
+// (отставший подписчик получает ResourceExhausted и должен догнать состояние через Sync)
+func (s *Server) WatchChanges(req *calendarpb.WatchChangesRequest, stream grpc.ServerStreamingServer[calendarpb.Change]) error {
+
+	if _, ok := s.repo.(storage.Notifier); !ok {
+		return status.Error(codes.Unimplemented, "хранилище не поддерживает уведомления об изменениях")
+	}
+	if req.GetUserId() < 0 {
+		return invalid("user_id не может быть отрицательным")
+	}
+
+	w := &watcher{
+		userID:  int(req.GetUserId()),
+		types:   make(map[storage.ChangeType]bool),
+		changes: make(chan storage.Change, watchBuffer),
+		lagged:  make(chan struct{}),
+	}
+	known := make(map[storage.ChangeType]bool)
+	for _, changeType := range storage.ChangeTypes() {
+		known[changeType] = true
+	}
+	for _, name := range req.GetTypes() {
+		changeType := storage.ChangeType(strings.TrimSpace(name))
+		if !known[changeType] {
+			return invalid("неизвестный тип изменения %q", name)
+		}
+		w.types[changeType] = true
+	}
+
+	s.watchersMu.Lock()
+	s.watchers[w] = struct{}{}
+	s.watchersMu.Unlock()
+
+	defer func() {
+		s.watchersMu.Lock()
+		delete(s.watchers, w)
+		s.watchersMu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.closed:
+			return status.Error(codes.Unavailable, "сервер останавливается")
+		case change := <-w.changes:
+			if err := stream.Send(toChange(change)); err != nil {
+				return err
+			}
+		case <-w.lagged:
+			return status.Error(codes.ResourceExhausted, "поток не успевает за изменениями, синхронизируйтесь через Sync")
+		}
+	}
+}
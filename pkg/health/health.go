@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// checkTimeoutDefault - сколько ждать одну проверку, если у запроса нет своего дедлайна
+const checkTimeoutDefault = 2 * time.Second
+
+// Check проверяет одну зависимость сервера (nil - всё в порядке)
+type Check func(ctx context.Context) error
+
+// Registry - проверки готовности сервера и признак остановки
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+
+	draining atomic.Bool // сервер останавливается - новые запросы не нужны
+	timeout  time.Duration
+}
+
+// Report - ответ /healthz и /readyz
+type Report struct {
+	Status string            `json:"status"`           // ok или unavailable
+	Checks map[string]string `json:"checks,omitempty"` // результат каждой проверки: ok или текст ошибки
+}
+
+// New создаёт пустой реестр проверок
+func New() *Registry {
+
+	return &Registry{
+		checks:  make(map[string]Check),
+		timeout: checkTimeoutDefault,
+	}
+}
+
+// Register добавляет проверку готовности (проверка с тем же именем заменяется)
+func (r *Registry) Register(name string, check Check) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = check
+}
+
+// Drain отмечает, что сервер останавливается: /readyz начинает отвечать 503,
+// чтобы балансировщик перестал присылать запросы до закрытия соединений
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Draining сообщает, вызван ли Drain
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Ready выполняет все проверки параллельно и возвращает отчёт (ok - сервер готов принимать запросы)
+func (r *Registry) Ready(ctx context.Context) (Report, bool) {
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ok", Checks: make(map[string]string, len(names))}
+	ready := true
+	for i, name := range names {
+		report.Checks[name] = "ok"
+		if results[i] != nil {
+			report.Checks[name] = results[i].Error()
+			ready = false
+		}
+	}
+	if r.Draining() {
+		report.Checks["shutdown"] = "сервер останавливается"
+		ready = false
+	}
+	if !ready {
+		report.Status = "unavailable"
+	}
+
+	return report, ready
+}
+
+// LivenessHandler отвечает 200, пока процесс жив и обрабатывает запросы (для GET /healthz)
+func (r *Registry) LivenessHandler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: "ok"})
+	})
+}
+
+// ReadinessHandler отвечает 200, если все проверки прошли и сервер не останавливается,
+// иначе 503 (для GET /readyz)
+func (r *Registry) ReadinessHandler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		report, ready := r.Ready(req.Context())
+		if !ready {
+			writeReport(w, http.StatusServiceUnavailable, report)
+			return
+		}
+
+		writeReport(w, http.StatusOK, report)
+	})
+}
+
+// Storage - проверка доступности хранилища: Ping, если хранилище его реализует (storage.Pinger),
+// иначе хранилище считается доступным
+func Storage(db storage.Repository) Check {
+
+	return func(ctx context.Context) error {
+		if pinger, ok := db.(storage.Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+		return nil
+	}
+}
+
+// writeReport отправляет отчёт в JSON без кэширования
+func writeReport(w http.ResponseWriter, status int, report Report) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,222 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+const (
+	prodID     = "-//calendar-server//calctl//RU"
+	lineLimit  = 75 // максимальная длина строки в октетах (дальше - перенос)
+	dateLayout = "20060102"
+	timeLayout = "20060102T150405"
+)
+
+// Encode записывает события в w как VCALENDAR (события целого дня)
+func Encode(w io.Writer, events []*storage.Event) error {
+
+	bw := bufio.NewWriter(w)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+prodID)
+	for _, event := range events {
+		stamp := event.UpdatedAt
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, fmt.Sprintf("UID:%d-%d@calendar-server", event.UserID, event.ID))
+		writeLine(bw, "DTSTAMP:"+stamp.UTC().Format(timeLayout)+"Z")
+		writeLine(bw, "DTSTART;VALUE=DATE:"+event.Date.Format(dateLayout))
+		writeLine(bw, "SUMMARY:"+escape(event.Title))
+		if event.Content != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(event.Content))
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+	writeLine(bw, "END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// writeLine пишет строку, перенося её по 75 октетов (продолжение начинается с пробела)
+func writeLine(w *bufio.Writer, line string) {
+
+	limit := lineLimit
+	for len(line) > limit {
+		// не режем многобайтовый символ пополам
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = lineLimit - 1 // пробел в начале продолжения тоже считается
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// Decode читает события из VCALENDAR; ID и пользователь не заполняются
+// (поддерживаются DTSTART как дата и как дата-время, SUMMARY и DESCRIPTION)
+func Decode(r io.Reader) ([]*storage.Event, error) {
+
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*storage.Event, 0)
+	var current *storage.Event
+	nested := 0 // глубина вложенных в VEVENT компонентов (VALARM и т.п.): их свойства не относятся к событию
+	for n, line := range lines {
+		name, params, value, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case current != nil && name == "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				return nil, fmt.Errorf("строка %d: VEVENT внутри VEVENT", n+1)
+			}
+			nested++
+		case current != nil && nested > 0:
+			if name == "END" {
+				nested--
+			}
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			current = &storage.Event{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if current == nil {
+				return nil, fmt.Errorf("строка %d: END:VEVENT без BEGIN:VEVENT", n+1)
+			}
+			if current.Date.IsZero() {
+				return nil, fmt.Errorf("строка %d: у события нет DTSTART", n+1)
+			}
+			if current.Title == "" {
+				return nil, fmt.Errorf("строка %d: у события нет SUMMARY", n+1)
+			}
+			events = append(events, current)
+			current = nil
+		case current == nil:
+			continue
+		case name == "DTSTART":
+			date, err := parseDate(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("строка %d: %w", n+1, err)
+			}
+			current.Date = date
+		case name == "SUMMARY":
+			current.Title = unescape(value)
+		case name == "DESCRIPTION":
+			current.Content = unescape(value)
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("не закрыт VEVENT")
+	}
+
+	return events, nil
+}
+
+// unfold склеивает перенесённые строки
+func unfold(r io.Reader) ([]string, error) {
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseLine разбирает строку вида NAME;PARAM=VALUE:значение
+// (значения параметров в кавычках могут содержать ':' и ';', например ALTREP="http://...")
+func parseLine(line string) (string, map[string]string, string, bool) {
+
+	head, value, ok := cutUnquoted(line, ':')
+	if !ok {
+		return "", nil, "", false
+	}
+
+	name, rest, _ := cutUnquoted(head, ';')
+	params := make(map[string]string)
+	for rest != "" {
+		var part string
+		part, rest, _ = cutUnquoted(rest, ';')
+		key, val, _ := strings.Cut(part, "=")
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+
+	return strings.ToUpper(name), params, value, true
+}
+
+// cutUnquoted делит строку по первому разделителю вне двойных кавычек
+func cutUnquoted(s string, sep byte) (string, string, bool) {
+
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				return s[:i], s[i+1:], true
+			}
+		}
+	}
+
+	return s, "", false
+}
+
+// parseDate разбирает DTSTART: дату или дату-время (в UTC или локальном времени)
+func parseDate(params map[string]string, value string) (time.Time, error) {
+
+	var t time.Time
+	var err error
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == len(dateLayout):
+		t, err = time.Parse(dateLayout, value)
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(timeLayout+"Z", value)
+	default:
+		t, err = time.Parse(timeLayout, value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный DTSTART %q", value)
+	}
+
+	// в календаре храним только день
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+var (
+	escaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+// escape экранирует текстовое значение
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// unescape снимает экранирование текстового значения
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// routeUnmatched - метка маршрута для запросов, не попавших ни в один маршрут (чтобы не плодить метки)
+const routeUnmatched = "unmatched"
+
+// Metrics - метрики сервера в формате Prometheus
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec   // запросы по методу, маршруту и статусу
+	requestDuration *prometheus.HistogramVec // длительность запросов по методу, маршруту и статусу
+	storageOps      *prometheus.CounterVec   // вызовы хранилища по методу и результату
+	storageDuration *prometheus.HistogramVec // длительность вызовов хранилища по методу
+}
+
+// New создаёт метрики в собственном реестре (вместе со стандартными метриками Go и процесса)
+func New() *Metrics {
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calendar_http_requests_total",
+			Help: "Количество HTTP-запросов по методу, маршруту и статусу.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "calendar_http_request_duration_seconds",
+			Help:    "Длительность HTTP-запросов по методу, маршруту и статусу.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		storageOps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calendar_storage_operations_total",
+			Help: "Количество вызовов хранилища по методу и результату (ok, not_found, conflict, error).",
+		}, []string{"method", "result"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "calendar_storage_operation_duration_seconds",
+			Help:    "Длительность вызовов хранилища по методу.",
+			Buckets: []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.storageOps,
+		m.storageDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Registry возвращает реестр, чтобы добавить в него свои метрики
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus (для GET /metrics)
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// statusRecorder запоминает статус ответа
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap даёт http.ResponseController добраться до исходного ResponseWriter
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Middleware считает запросы и их длительность
+// (маршрут берётся из шаблона ServeMux, например /event/{id}, чтобы ID не попадали в метки)
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		// ServeMux записывает найденный шаблон в тот же запрос
+		route := routeUnmatched
+		if r.Pattern != "" {
+			_, path, found := strings.Cut(r.Pattern, " ")
+			if !found {
+				path = r.Pattern
+			}
+			route = path
+		}
+
+		status := strconv.Itoa(rec.status)
+		m.requests.WithLabelValues(r.Method, route, status).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveStorage учитывает вызов хранилища (подходит как storage.Observer)
+func (m *Metrics) ObserveStorage(method string, duration time.Duration, err error) {
+
+	result := "ok"
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrNotFound):
+		result = "not_found"
+	case errors.Is(err, storage.ErrVersionConflict):
+		result = "conflict"
+	default:
+		result = "error"
+	}
+
+	m.storageOps.WithLabelValues(method, result).Inc()
+	m.storageDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// WatchStorage подписывается на операции хранилища и добавляет gauge с числом пользователей и событий
+// (если хранилище не storage.Observable, ничего не делает)
+func (m *Metrics) WatchStorage(db storage.Repository) {
+
+	observable, ok := db.(storage.Observable)
+	if !ok {
+		return
+	}
+
+	observable.Observe(m.ObserveStorage)
+
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "calendar_storage_users",
+			Help: "Количество пользователей, у которых есть события.",
+		}, func() float64 { return float64(observable.Stats().Users) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "calendar_storage_events",
+			Help: "Количество событий в хранилище (без корзины).",
+		}, func() float64 { return float64(observable.Stats().Events) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "calendar_storage_trashed_events",
+			Help: "Количество событий в корзине.",
+		}, func() float64 { return float64(observable.Stats().Trashed) }),
+	)
+}
@@ -2,39 +2,89 @@ package server
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
+
+	"github.com/IPampurin/calendar-server/pkg/config"
 )
 
-// SetupLogging создает и настраивает логгер с записью в файл,
-// возвращает логгер и файл для закрытия или ошибку
-func SetupLogging() (*log.Logger, *os.File, error) {
+// NewLogger создаёт slog-логгер: format - text или json, level - debug, info, warn или error
+// (пустые значения - text и info)
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("неверный уровень логирования %q, используйте debug, info, warn или error", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
 
-	// создаем папку logs если нет (владелец читает/пишет/исполняет, остальные читают/исполняют)
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		return nil, nil, fmt.Errorf("не удалось создать папку logs: %w", err)
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
 	}
 
-	// генерируем имя файла с текущей датой
-	filename := fmt.Sprintf("logs/calendar_%s.log", time.Now().Format("2006-01-02"))
+	return nil, fmt.Errorf("неверный формат логов %q, используйте text или json", format)
+}
+
+// SetupLogging создает и настраивает логгер с записью в ротируемые по дням файлы по настройкам cfg,
+// возвращает логгер и writer для закрытия или ошибку
+func SetupLogging(cfg config.LogConfig) (*slog.Logger, *RotatingWriter, error) {
+
+	// проверяем формат и уровень до того, как открывать файл
+	if _, err := NewLogger(io.Discard, cfg.Format, cfg.Level); err != nil {
+		return nil, nil, err
+	}
 
-	// открываем файл для записи:
-	// - os.O_CREATE: создать если не существует
-	// - os.O_WRONLY: только запись
-	// - os.O_APPEND: дописывать в конец (не перезаписывать)
-	// - 0666: права чтения/записи для всех
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	// файл logs/calendar_YYYY-MM-DD.log, в полночь начинается новый
+	writer, err := NewRotatingWriter(RotateConfig{
+		Dir:      cfg.Dir,
+		Prefix:   "calendar",
+		MaxSize:  int64(cfg.MaxSizeMB) << 20,
+		MaxFiles: cfg.MaxFiles,
+		MaxAge:   cfg.MaxAge,
+		Compress: cfg.Compress,
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("не удалось открыть файл логов: %w", err)
+		return nil, nil, err
 	}
-	// закрываем файл из server.Run
+	// закрываем writer из server.Run
 
-	// создаем логгер с настройками:
-	// - file: куда писать логи
-	// - "": префикс пустой
-	// - log.LstdFlags == 0 (флаги даты и времени (2009/01/23 01:23:23)) у нас и так пишутся
-	logger := log.New(file, "", 0)
+	// создаем логгер, который пишет в writer (время, уровень и поля добавляет slog)
+	logger, _ := NewLogger(writer, cfg.Format, cfg.Level)
 
-	return logger, file, nil
+	return logger, writer, nil
+}
+
+// ReopenOnSIGHUP заново открывает файл логов по SIGHUP (для внешнего logrotate),
+// возвращает функцию, которая прекращает слушать сигнал
+func ReopenOnSIGHUP(writer *RotatingWriter) func() {
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				if err := writer.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "Ошибка переоткрытия логов: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
 }
@@ -1,16 +1,53 @@
 package server
 
 import (
-	"fmt"
-	"log"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/IPampurin/calendar-server/pkg/tracing"
 )
 
-// responseWriter для захвата статуса
+// HeaderRequestID - заголовок с ID запроса (берётся из запроса или генерируется, возвращается в ответе)
+const HeaderRequestID = "X-Request-ID"
+
+// requestIDMaxLen - ID запроса длиннее этого заменяется своим
+const requestIDMaxLen = 128
+
+// logBodyPeekLimit - сколько тела POST читается заранее ради user_id в логе (v1 передаёт его в теле)
+const logBodyPeekLimit = 64 << 10
+
+// redactedParams - параметры запроса, значения которых не пишутся в лог
+var redactedParams = []string{"token", "access_token", "password", "secret", "key", "api_key", "signature"}
+
+// requestIDKey - ключ контекста для ID запроса
+type requestIDKey struct{}
+
+// RequestID возвращает ID запроса из контекста ("" - запрос не проходил через LoggingMiddleware)
+func RequestID(ctx context.Context) string {
+
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// responseWriter для захвата статуса и размера ответа
 type responseWriter struct {
 	http.ResponseWriter
 	status int
+	size   int
 }
 
 // WriteHeader для получения статуса в ответе сервера
@@ -20,9 +57,21 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code) // вызываем оригинальный метод
 }
 
+// Write считает, сколько байт ушло в ответ
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Unwrap даёт http.ResponseController добраться до исходного ResponseWriter (Flush и т.п.)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // LoggingMiddleware создает middleware логирования HTTP запросов
 // (принимает логгер и возвращает функцию-обертку для обработчиков)
-func LoggingMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
+func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 	// возвращаем функцию, которая принимает следующий обработчик (next)
 	return func(next http.Handler) http.Handler {
 		// возвращаем новый обработчик, который логирует и вызывает next
@@ -31,23 +80,240 @@ func LoggingMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
 			// фиксируем время получения запроса
 			requestTime := time.Now()
 
+			// ID запроса: от клиента или прокси, иначе свой
+			requestID := r.Header.Get(HeaderRequestID)
+			if requestID == "" || len(requestID) > requestIDMaxLen {
+				requestID = newRequestID()
+			}
+			w.Header().Set(HeaderRequestID, requestID)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID))
+
 			// создаем экземпляр ResponseWriter для захвата статуса
 			rw := &responseWriter{
 				ResponseWriter: w,
 				status:         http.StatusOK,
 			}
 
+			// user_id v1 лежит в теле: читаем начало заранее, после обработчика тело уже вычитано
+			var body []byte
+			if r.Method == http.MethodPost && r.URL.Query().Get("user_id") == "" {
+				body = peekBody(r, logBodyPeekLimit)
+			}
+
 			// вызываем следующий обработчик в цепочке (это может быть другой middleware или финальный хендлер)
 			next.ServeHTTP(rw, r)
 
-			// формируем строку лога: время метод путь статус IP
-			logLine := requestTime.Format("2006-01-02 15:04:05") + " " + // время получения запроса
-				r.Method + " " + // метод запроса
-				r.URL.Path + " " + // эндпойнт
-				fmt.Sprintf("%d", rw.status) + " " + // статус
-				r.RemoteAddr // IP откуда был запрос
+			// 5xx - ошибка, 4xx - предупреждение, остальное - информация
+			level := slog.LevelInfo
+			switch {
+			case rw.status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case rw.status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
 
-			logger.Println(logLine)
+			attrs := []slog.Attr{
+				slog.String("request_id", requestID),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.status),
+				slog.Int("size", rw.size),
+				slog.Float64("latency_ms", float64(time.Since(requestTime).Microseconds())/1000),
+				slog.String("client_ip", clientIP(r)),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if traceID := tracing.TraceID(r.Context()); traceID != "" {
+				attrs = append(attrs, slog.String("trace_id", traceID))
+			}
+			if query := redactQuery(r.URL.Query()); query != "" {
+				attrs = append(attrs, slog.String("query", query))
+			}
+			if userID := requestUserID(r, body); userID != 0 {
+				attrs = append(attrs, slog.Int("user_id", userID))
+			}
+			if userAgent := r.UserAgent(); userAgent != "" {
+				attrs = append(attrs, slog.String("user_agent", userAgent))
+			}
+
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
 		})
 	}
 }
+
+// recoveryWriter запоминает, начал ли хэндлер отвечать
+type recoveryWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoveryWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoveryWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap даёт http.ResponseController добраться до исходного ResponseWriter
+func (w *recoveryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RecoveryMiddleware перехватывает панику в хэндлере: пишет в журнал стек и отвечает 500
+// с api.Answer и ID запроса (ставится внутри LoggingMiddleware, чтобы ID уже был в контексте)
+func RecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			rw := &recoveryWriter{ResponseWriter: w}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// http.ErrAbortHandler - намеренный обрыв ответа, net/http обработает его сам
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				requestID := RequestID(r.Context())
+				logger.LogAttrs(r.Context(), slog.LevelError, "panic",
+					slog.String("request_id", requestID),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Any("error", recovered),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				// если ответ уже начат, статус не поменять - соединение просто закроется
+				if rw.wroteHeader {
+					return
+				}
+
+				var answer api.Answer
+				answer.Error = "внутренняя ошибка сервера"
+				answer.RequestID = requestID
+				api.WriterJSON(rw, http.StatusInternalServerError, answer) // 500
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// newRequestID генерирует случайный ID запроса
+func newRequestID() string {
+
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+
+	return hex.EncodeToString(b[:])
+}
+
+// clientIP возвращает адрес клиента: первый из X-Forwarded-For, X-Real-IP или адрес соединения
+func clientIP(r *http.Request) string {
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := strings.TrimSpace(first); ip != "" {
+			return ip
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// peekBody читает начало тела запроса (не больше limit байт, limit меньше нуля - целиком) и возвращает его,
+// а r.Body подменяет так, чтобы хэндлер получил тело целиком
+func peekBody(r *http.Request, limit int64) []byte {
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	var reader io.Reader = r.Body
+	if limit >= 0 {
+		reader = io.LimitReader(r.Body, limit)
+	}
+	body, _ := io.ReadAll(reader)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	return body
+}
+
+// bodyUserIDs возвращает user_id из тела v1: поле запроса и поля операций пакета
+// (тело не JSON или обрезано - пусто)
+func bodyUserIDs(body []byte) []int {
+
+	var payload struct {
+		UserID     int `json:"user_id"`
+		Operations []*struct {
+			UserID int `json:"user_id"`
+		} `json:"operations"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil
+	}
+
+	var ids []int
+	if payload.UserID != 0 {
+		ids = append(ids, payload.UserID)
+	}
+	for _, op := range payload.Operations {
+		if op != nil && op.UserID != 0 {
+			ids = append(ids, op.UserID)
+		}
+	}
+
+	return ids
+}
+
+// requestUserID определяет пользователя запроса: ID из пути, параметр user_id, поле user_id
+// в прочитанном заранее начале тела или X-Actor-ID (0 - пользователь не указан)
+func requestUserID(r *http.Request, body []byte) int {
+
+	if userID, err := strconv.Atoi(r.PathValue("id")); err == nil {
+		return userID
+	}
+	if userID, err := strconv.Atoi(r.URL.Query().Get("user_id")); err == nil {
+		return userID
+	}
+	if ids := bodyUserIDs(body); len(ids) > 0 {
+		return ids[0]
+	}
+	if userID, err := strconv.Atoi(r.Header.Get(api.HeaderActorID)); err == nil {
+		return userID
+	}
+
+	return 0
+}
+
+// redactQuery кодирует параметры запроса, скрывая значения секретов
+func redactQuery(query url.Values) string {
+
+	for name := range query {
+		lower := strings.ToLower(name)
+		for _, secret := range redactedParams {
+			if lower == secret {
+				query[name] = []string{"REDACTED"}
+				break
+			}
+		}
+	}
+
+	return query.Encode()
+}
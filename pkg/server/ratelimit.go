@@ -0,0 +1,207 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+)
+
+const (
+	rateLimitSweepInterval = time.Minute // как часто выбрасывать бакеты, которые давно не использовались
+	rateLimitPeekDefault   = 1 << 20     // сколько тела читать в поисках user_id, если MaxBodySize не задан
+)
+
+// RateLimitConfig - ограничение частоты запросов к API (token bucket)
+type RateLimitConfig struct {
+	UserRate   float64 // запросов в секунду на пользователя (0 - без ограничения)
+	UserBurst  int     // сколько запросов пользователь может сделать подряд (0 - округлённый вверх UserRate)
+	IPRate     float64 // запросов в секунду на IP клиента (0 - без ограничения)
+	IPBurst    int     // сколько запросов с одного IP можно сделать подряд (0 - округлённый вверх IPRate)
+	TrustProxy bool    // брать IP клиента из X-Forwarded-For / X-Real-IP (только за своим прокси)
+
+	MaxBodySize int64 // сколько тела запроса читать в поисках user_id (сервер берёт свой лимит тела; 0 и меньше - 1 МБ)
+}
+
+// enabled сообщает, включено ли хоть одно ограничение
+func (c RateLimitConfig) enabled() bool {
+	return c.UserRate > 0 || c.IPRate > 0
+}
+
+// bucket - токены одного ключа
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// limiter - набор token bucket по ключам (пользователь или IP)
+type limiter struct {
+	rate  float64 // токенов в секунду
+	burst float64 // ёмкость бакета
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// newLimiter создаёт ограничитель (nil - ограничение выключено)
+func newLimiter(rate float64, burst int) *limiter {
+
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+
+	return &limiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket), swept: time.Now()}
+}
+
+// allow забирает токен ключа; если токенов нет - возвращает, через сколько появится следующий
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	// пополняем бакет за прошедшее время, но не больше ёмкости
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep удаляет бакеты, которые успели наполниться: они не отличаются от новых (вызывается под l.mu)
+func (l *limiter) sweep(now time.Time) {
+
+	if now.Sub(l.swept) < rateLimitSweepInterval {
+		return
+	}
+	l.swept = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware ограничивает частоту запросов с одного IP и от одного пользователя;
+// сверх лимита - 429 с Retry-After (в секундах); запросы без пользователя ограничиваются только по IP
+func RateLimitMiddleware(cfg RateLimitConfig) func(http.Handler) http.Handler {
+
+	users := newLimiter(cfg.UserRate, cfg.UserBurst)
+	ips := newLimiter(cfg.IPRate, cfg.IPBurst)
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = rateLimitPeekDefault
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			now := time.Now()
+
+			// сначала IP: так перебор user_id с одного адреса тоже упирается в лимит
+			if ips != nil {
+				if ok, wait := ips.allow(rateLimitIP(r, cfg.TrustProxy), now); !ok {
+					tooManyRequests(w, wait)
+					return
+				}
+			}
+			if users != nil {
+				if userID := rateLimitUserID(r, cfg.MaxBodySize); userID != 0 {
+					if ok, wait := users.allow(strconv.Itoa(userID), now); !ok {
+						tooManyRequests(w, wait)
+						return
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tooManyRequests отвечает 429 с Retry-After, округлённым вверх до секунды
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+
+	var answer api.Answer
+
+	seconds := max(1, int(math.Ceil(wait.Seconds())))
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	answer.Error = fmt.Sprintf("слишком много запросов, повторите через %d с", seconds)
+	api.WriterJSON(w, http.StatusTooManyRequests, answer) // 429
+}
+
+// rateLimitIP возвращает IP клиента для лимита (заголовкам прокси верим, только если это разрешено)
+func rateLimitIP(r *http.Request, trustProxy bool) string {
+
+	if trustProxy {
+		return clientIP(r)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// rateLimitUserID определяет, в чей бакет идёт запрос (0 - только лимит по IP):
+// пользователь из сертификата клиента, а без него - владелец изменяемых событий из пути v2
+// или из тела v1 (для пакета - первой операции); X-Actor-ID и ?user_id клиент подставляет
+// сам, поэтому они не учитываются
+func rateLimitUserID(r *http.Request, peekLimit int64) int {
+
+	if userID := certUserID(r.Context()); userID != 0 {
+		return userID
+	}
+	if userID := pathUserID(r.URL.Path); userID != 0 {
+		return userID
+	}
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		if ids := bodyUserIDs(peekBody(r, peekLimit)); len(ids) > 0 {
+			return ids[0]
+		}
+	}
+
+	return 0
+}
+
+// pathUserID ищет ID пользователя в пути v2 (/users/{id}/...) до маршрутизации (0 - нет)
+func pathUserID(path string) int {
+
+	_, rest, found := strings.Cut(path, "/users/")
+	if !found {
+		return 0
+	}
+	segment, _, _ := strings.Cut(rest, "/")
+	if userID, err := strconv.Atoi(segment); err == nil && userID > 0 {
+		return userID
+	}
+
+	return 0
+}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateConfig - настройки ротации файлов логов
+type RotateConfig struct {
+	Dir      string           // папка логов
+	Prefix   string           // начало имени файла: <Prefix>_YYYY-MM-DD.log
+	MaxSize  int64            // размер файла в байтах, после которого начинается новый (0 - только по дням)
+	MaxFiles int              // сколько старых файлов хранить (0 - без ограничения)
+	MaxAge   time.Duration    // сколько хранить старые файлы (0 - без ограничения)
+	Compress bool             // сжимать старые файлы в .gz
+	Now      func() time.Time // источник времени (nil - time.Now)
+}
+
+// RotatingWriter пишет лог в файл текущего дня: в полночь (и при превышении MaxSize)
+// переключается на новый файл, старые сжимает и удаляет сверх MaxFiles/MaxAge
+type RotatingWriter struct {
+	cfg RotateConfig
+
+	mu   sync.Mutex
+	file *os.File
+	day  string // дата текущего файла (YYYY-MM-DD)
+	size int64  // сколько уже записано в текущий файл
+
+	millMu sync.Mutex     // сжатие и очистка идут по одной
+	mills  sync.WaitGroup // фоновые сжатия и очистки, которых ждёт Close
+}
+
+// NewRotatingWriter открывает (или дописывает) файл текущего дня
+func NewRotatingWriter(cfg RotateConfig) (*RotatingWriter, error) {
+
+	if cfg.Dir == "" {
+		cfg.Dir = "logs"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "calendar"
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("не удалось создать папку %s: %w", cfg.Dir, err)
+	}
+
+	w := &RotatingWriter{cfg: cfg}
+	if err := w.open(cfg.Now().Format("2006-01-02")); err != nil {
+		return nil, err
+	}
+
+	// файлы, оставшиеся с прошлых запусков, тоже сжимаем и чистим
+	w.mill()
+
+	return w, nil
+}
+
+// Write пишет в текущий файл, при необходимости сначала переключаясь на новый
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	day := w.cfg.Now().Format("2006-01-02")
+	switch {
+	case day != w.day:
+		if err := w.rotate(day, false); err != nil {
+			return 0, err
+		}
+	case w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.cfg.MaxSize:
+		if err := w.rotate(day, true); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Reopen закрывает и заново открывает файл текущего дня
+// (после того как внешний logrotate переименовал его)
+func (w *RotatingWriter) Reopen() error {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть файл логов: %w", err)
+	}
+	w.file = nil
+
+	return w.open(w.cfg.Now().Format("2006-01-02"))
+}
+
+// Close закрывает файл и дожидается фонового сжатия и очистки
+func (w *RotatingWriter) Close() error {
+
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.mills.Wait()
+
+	return err
+}
+
+// Filename возвращает путь текущего файла
+func (w *RotatingWriter) Filename() string {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.filename(w.day)
+}
+
+// filename - путь файла за день
+func (w *RotatingWriter) filename(day string) string {
+	return filepath.Join(w.cfg.Dir, fmt.Sprintf("%s_%s.log", w.cfg.Prefix, day))
+}
+
+// open открывает файл дня на дозапись (вызывается под mu)
+func (w *RotatingWriter) open(day string) error {
+
+	file, err := os.OpenFile(w.filename(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл логов: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("не удалось открыть файл логов: %w", err)
+	}
+
+	w.file = file
+	w.day = day
+	w.size = info.Size()
+
+	return nil
+}
+
+// rotate закрывает текущий файл и открывает файл дня day (вызывается под mu);
+// bySize - файл переполнен: он переименовывается в <Prefix>_YYYY-MM-DD.N.log
+func (w *RotatingWriter) rotate(day string, bySize bool) error {
+
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть файл логов: %w", err)
+	}
+	w.file = nil
+
+	if bySize {
+		current := w.filename(w.day)
+		base := strings.TrimSuffix(current, ".log")
+		for n := 1; ; n++ {
+			name := fmt.Sprintf("%s.%d.log", base, n)
+			if !exists(name) && !exists(name+".gz") {
+				if err := os.Rename(current, name); err != nil {
+					return fmt.Errorf("не удалось переименовать файл логов: %w", err)
+				}
+				break
+			}
+		}
+	}
+
+	if err := w.open(day); err != nil {
+		return err
+	}
+
+	w.mill()
+
+	return nil
+}
+
+// mill в фоне сжимает старые файлы и удаляет лишние
+func (w *RotatingWriter) mill() {
+
+	w.mills.Add(1)
+	go func() {
+		defer w.mills.Done()
+
+		w.millMu.Lock()
+		defer w.millMu.Unlock()
+
+		// список берём под mu, чтобы не принять за старый только что открытый файл
+		w.mu.Lock()
+		files, err := w.oldFiles(w.filename(w.day))
+		w.mu.Unlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка очистки логов: %v\n", err)
+			return
+		}
+
+		if err := w.compressOld(files); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка сжатия логов: %v\n", err)
+		}
+		if err := w.removeOld(files); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка очистки логов: %v\n", err)
+		}
+	}()
+}
+
+// logFile - старый файл логов
+type logFile struct {
+	name    string // имя в папке логов
+	modTime time.Time
+}
+
+// oldFiles возвращает старые файлы логов (все, кроме current), от новых к старым
+func (w *RotatingWriter) oldFiles(current string) ([]*logFile, error) {
+
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*logFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, w.cfg.Prefix+"_") {
+			continue
+		}
+		if !strings.HasSuffix(name, ".log") && !strings.HasSuffix(name, ".log.gz") {
+			continue
+		}
+		if filepath.Join(w.cfg.Dir, name) == current {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // файл успели удалить
+		}
+		files = append(files, &logFile{name: name, modTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.After(files[j].modTime)
+		}
+		return files[i].name > files[j].name
+	})
+
+	return files, nil
+}
+
+// compressOld сжимает старые .log в .log.gz
+func (w *RotatingWriter) compressOld(files []*logFile) error {
+
+	if !w.cfg.Compress {
+		return nil
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.name, ".log") {
+			continue
+		}
+		if err := gzipFile(filepath.Join(w.cfg.Dir, file.name)); err != nil {
+			return err
+		}
+		file.name += ".gz"
+	}
+
+	return nil
+}
+
+// removeOld удаляет файлы сверх MaxFiles и старше MaxAge
+func (w *RotatingWriter) removeOld(files []*logFile) error {
+
+	if w.cfg.MaxFiles <= 0 && w.cfg.MaxAge <= 0 {
+		return nil
+	}
+
+	cutoff := w.cfg.Now().Add(-w.cfg.MaxAge)
+	for i, file := range files {
+		tooMany := w.cfg.MaxFiles > 0 && i >= w.cfg.MaxFiles
+		tooOld := w.cfg.MaxAge > 0 && file.modTime.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(filepath.Join(w.cfg.Dir, file.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gzipFile сжимает файл в name.gz с тем же временем изменения и удаляет исходный
+func gzipFile(name string) error {
+
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	zw.ModTime = info.ModTime()
+
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	// время изменения сохраняем, чтобы MaxAge и порядок файлов считались от записи, а не от сжатия
+	if err := os.Chtimes(name+".gz", info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// exists проверяет, есть ли файл
+func exists(name string) bool {
+
+	_, err := os.Stat(name)
+
+	return err == nil
+}
@@ -2,83 +2,524 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/IPampurin/calendar-server/pkg/config"
+	"github.com/IPampurin/calendar-server/pkg/grpcapi"
+	"github.com/IPampurin/calendar-server/pkg/health"
+	"github.com/IPampurin/calendar-server/pkg/metrics"
 	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/IPampurin/calendar-server/pkg/tracing"
+	"github.com/IPampurin/calendar-server/pkg/webhook"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-const calendarPortDefault = "8081"
+const (
+	calendarPortDefault       = "8081"
+	trashPurgeIntervalDefault = time.Hour        // как часто чистить корзину от просроченных событий
+	shutdownTimeoutDefault    = 30 * time.Second // сколько ждать завершения запросов при остановке
 
-// Run запускает сервер и передаёт объект хранилища хэндлерам
-func Run(db storage.Repository) error {
+	readHeaderTimeoutDefault = 5 * time.Second   // сколько ждать заголовки запроса
+	readTimeoutDefault       = 30 * time.Second  // сколько ждать запрос целиком
+	writeTimeoutDefault      = 60 * time.Second  // сколько можно писать ответ
+	idleTimeoutDefault       = 120 * time.Second // сколько держать простаивающее keep-alive соединение
+)
+
+// Config - настройки сервера
+type Config struct {
+	Port               string          // порт HTTP ("0" - любой свободный)
+	GRPCPort           string          // порт gRPC ("" - gRPC не запускается, "0" - любой свободный)
+	WebhookOutbox      string          // файл подписок и outbox вебхуков ("" - только в памяти: у каждого экземпляра свои)
+	IdempotencyWindow  time.Duration   // сколько хранить ответы для Idempotency-Key (0 - по умолчанию)
+	ShutdownTimeout    time.Duration   // сколько Run ждёт завершения запросов при остановке (0 - 30 секунд)
+	DrainDelay         time.Duration   // сколько Run ждёт после сигнала, пока балансировщик уберёт сервер (0 - сразу останавливаться)
+	TrashPurgeInterval time.Duration   // как часто чистить корзину (0 - раз в час)
+	ReadHeaderTimeout  time.Duration   // таймаут чтения заголовков (0 - 5 секунд, меньше нуля - без таймаута)
+	ReadTimeout        time.Duration   // таймаут чтения запроса целиком (0 - 30 секунд, меньше нуля - без таймаута)
+	WriteTimeout       time.Duration   // таймаут записи ответа (0 - 60 секунд, меньше нуля - без таймаута)
+	IdleTimeout        time.Duration   // таймаут простоя keep-alive соединения (0 - 120 секунд, меньше нуля - без таймаута)
+	MaxBodySize        int64           // наибольший размер тела запроса в байтах, больше - 413 (0 - 1 МБ, меньше нуля - без ограничения)
+	TLS                *TLSConfig      // HTTPS и gRPC по TLS (nil - без шифрования)
+	H2C                bool            // HTTP/2 без TLS (h2c) для прокси перед сервером
+	RateLimit          RateLimitConfig // ограничение частоты запросов к API (нулевое - без ограничения)
+	Logger             *slog.Logger    // журнал запросов и работы сервера (nil - slog.Default())
+	APIOptions         []api.Option    // дополнительные опции API (префикс, middleware)
+}
+
+// Server - HTTP и gRPC серверы календаря с общим хранилищем, вебхуками и очисткой корзины
+// (можно встроить в своё приложение: New, Start, Stop)
+type Server struct {
+	cfg    Config
+	db     storage.Repository
+	logger *slog.Logger
+
+	dispatcher  *webhook.Dispatcher
+	unsubscribe func() // отписывает диспетчер вебхуков от изменений хранилища
+	metrics     *metrics.Metrics
+	health      *health.Registry
+	certs       *CertReloader // nil - TLS выключен
+	httpServer  *http.Server
+	grpcService *grpcapi.Server
+	grpcServer  *grpc.Server // nil - gRPC выключен
+
+	mu           sync.Mutex
+	started      bool
+	httpListener net.Listener
+	grpcListener net.Listener
+	stopTasks    context.CancelFunc // останавливает вебхуки и очистку корзины
+	tasks        sync.WaitGroup
+	errs         chan error // ошибки, с которыми упали Serve
+}
+
+// New собирает сервер, но не открывает порты
+func New(db storage.Repository, cfg Config) (*Server, error) {
 
-	port, ok := os.LookupEnv("CALENDAR_PORT")
-	if !ok {
-		port = calendarPortDefault
+	if cfg.Port == "" {
+		cfg.Port = calendarPortDefault
 	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = shutdownTimeoutDefault
+	}
+	if cfg.TrashPurgeInterval <= 0 {
+		cfg.TrashPurgeInterval = trashPurgeIntervalDefault
+	}
+	cfg.ReadHeaderTimeout = orDefault(cfg.ReadHeaderTimeout, readHeaderTimeoutDefault)
+	cfg.ReadTimeout = orDefault(cfg.ReadTimeout, readTimeoutDefault)
+	cfg.WriteTimeout = orDefault(cfg.WriteTimeout, writeTimeoutDefault)
+	cfg.IdleTimeout = orDefault(cfg.IdleTimeout, idleTimeoutDefault)
 
-	// инициализируем api
-	api.Init(db)
+	s := &Server{
+		cfg:    cfg,
+		db:     db,
+		logger: cfg.Logger,
+		errs:   make(chan error, 2),
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
 
-	// настраиваем логирование
-	logger, logFile, err := SetupLogging()
+	// поднимаем диспетчер вебхуков и подписываем его на изменения хранилища
+	dispatcher, err := webhook.New(webhook.Config{Path: cfg.WebhookOutbox})
 	if err != nil {
-		return fmt.Errorf("ошибка настройки логирования: %w", err)
+		return nil, fmt.Errorf("ошибка настройки вебхуков: %w", err)
 	}
-	defer logFile.Close()
+	if notifier, ok := db.(storage.Notifier); ok {
+		s.unsubscribe = notifier.Subscribe(dispatcher.Notify)
+	}
+	s.dispatcher = dispatcher
+
+	// сертификаты загружаем сразу, чтобы ошибка в путях была видна до запуска
+	if cfg.TLS != nil {
+		s.certs, err = NewCertReloader(*cfg.TLS, s.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// роутер API, обёрнутый в логирование
+	opts := append([]api.Option{
+		api.WithWebhooks(dispatcher),
+		api.WithIdempotencyWindow(cfg.IdempotencyWindow),
+		api.WithMaxBodySize(cfg.MaxBodySize),
+	}, cfg.APIOptions...)
+
+	// проверки готовности: хранилище отвечает и сервер не останавливается
+	s.health = health.New()
+	s.health.Register("storage", health.Storage(db))
+
+	// метрики запросов и хранилища, GET /metrics и пробы - мимо API
+	s.metrics = metrics.New()
+	s.metrics.WatchStorage(db)
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", s.metrics.Handler())
+	mux.Handle("GET /healthz", s.health.LivenessHandler())
+	mux.Handle("GET /readyz", s.health.ReadinessHandler())
+	// лимит запросов - только на API, пробы и метрики балансировщику и Prometheus не ограничиваем
+	var apiHandler http.Handler = api.NewHandler(db, opts...)
+	if cfg.RateLimit.enabled() {
+		cfg.RateLimit.MaxBodySize = cfg.MaxBodySize
+		apiHandler = RateLimitMiddleware(cfg.RateLimit)(apiHandler)
+	}
+	mux.Handle("/", s.metrics.Middleware(apiHandler))
+	// span запроса открывается до логирования, чтобы trace_id попал в журнал;
+	// паника в хэндлере превращается в 500 внутри логирования, чтобы попасть в журнал запросов
+	var handler http.Handler = mux
+	if cfg.TLS != nil {
+		handler = ClientCertMiddleware(*cfg.TLS, cfg.MaxBodySize)(handler) // пользователь из сертификата клиента
+	}
+	handler = tracing.Middleware(LoggingMiddleware(s.logger)(RecoveryMiddleware(s.logger)(handler)))
+
+	// HTTP/1.1 всегда, HTTP/2 - по TLS и, если включено, без него (h2c)
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(cfg.H2C)
+
+	s.httpServer = &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		Protocols:         protocols,
+		ErrorLog:          slog.NewLogLogger(s.logger.Handler(), slog.LevelError), // ошибки соединений - в тот же журнал
+	}
+
+	// gRPC-сервер на отдельном порту с тем же хранилищем
+	if cfg.GRPCPort != "" {
+		s.grpcService = grpcapi.NewServer(db)
+		var grpcOpts []grpc.ServerOption
+		if s.certs != nil {
+			grpcOpts = append(grpcOpts,
+				grpc.Creds(credentials.NewTLS(s.certs.TLSConfig())),
+				grpc.ChainUnaryInterceptor(ClientCertUnaryInterceptor(*cfg.TLS)),
+				grpc.ChainStreamInterceptor(ClientCertStreamInterceptor(*cfg.TLS)),
+			)
+		}
+		s.grpcServer = grpc.NewServer(grpcOpts...)
+		s.grpcService.Register(s.grpcServer)
+	}
+
+	return s, nil
+}
 
-	// оборачиваем в middleware
-	handler := LoggingMiddleware(logger)(http.DefaultServeMux)
+// orDefault подставляет значение по умолчанию вместо нулевого таймаута
+// (отрицательный означает "без таймаута" и остаётся как есть: http.Server его не применяет)
+func orDefault(timeout, def time.Duration) time.Duration {
 
-	// создаем и настраиваем сервер
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: handler,
+	if timeout == 0 {
+		return def
 	}
 
-	// канал для shutdown
-	idleConnsClosed := make(chan struct{})
+	return timeout
+}
+
+// Handler возвращает HTTP-обработчик сервера (например, чтобы смонтировать его в свой роутер)
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// Metrics возвращает метрики сервера (например, чтобы добавить в реестр свои)
+func (s *Server) Metrics() *metrics.Metrics {
+	return s.metrics
+}
+
+// Health возвращает реестр проверок готовности (например, чтобы добавить проверку своего хранилища)
+func (s *Server) Health() *health.Registry {
+	return s.health
+}
+
+// Drain переводит /readyz в 503, не закрывая порты: балансировщик перестаёт слать запросы,
+// а текущие дорабатывают (Stop вызывает его сам)
+func (s *Server) Drain() {
+	s.health.Drain()
+}
+
+// Start открывает порты и запускает серверы и фоновые задачи (не блокируется)
+func (s *Server) Start() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("сервер уже запущен")
+	}
+
+	httpListener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("ошибка запуска сервера: %w", err)
+	}
 
-	// http.Handle("/", http.FileServer(http.Dir("web"))) // здесь может быть запуск фронтэнда
+	var grpcListener net.Listener
+	if s.grpcServer != nil {
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%s", s.cfg.GRPCPort))
+		if err != nil {
+			httpListener.Close()
+			return fmt.Errorf("ошибка запуска gRPC-сервера: %w", err)
+		}
+	}
+
+	s.started = true
+	s.httpListener = httpListener
+	s.grpcListener = grpcListener
+
+	// вебхуки и очистка корзины останавливаются вместе с сервером
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopTasks = cancel
+	s.dispatcher.Start(ctx)
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		purgeTrash(ctx, s.db, s.cfg.TrashPurgeInterval, s.logger)
+	}()
 
-	// горутина для graceful shutdown
+	serve := s.httpServer.Serve
+	if s.certs != nil {
+		s.httpServer.TLSConfig = s.certs.TLSConfig()
+		serve = func(l net.Listener) error { return s.httpServer.ServeTLS(l, "", "") }
+	}
 	go func() {
+		if err := serve(httpListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.errs <- fmt.Errorf("ошибка сервера: %w", err)
+		}
+	}()
+	s.logger.Info("Сервер запущен", "addr", httpListener.Addr().String(), "tls", s.certs != nil, "h2c", s.cfg.H2C)
+
+	if grpcListener != nil {
+		go func() {
+			if err := s.grpcServer.Serve(grpcListener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				s.errs <- fmt.Errorf("ошибка gRPC-сервера: %w", err)
+			}
+		}()
+		s.logger.Info("gRPC-сервер запущен", "addr", grpcListener.Addr().String())
+	}
+
+	return nil
+}
 
-		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, syscall.SIGINT, syscall.SIGTERM)
-		<-sigint
+// Addr возвращает адрес, на котором слушает HTTP (после Start)
+func (s *Server) Addr() string {
 
-		logger.Println("Получен сигнал остановки сервера.")
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		// останавливаем сервер (до окончания текущего соединения или 30 секунд)
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	if s.httpListener == nil {
+		return ""
+	}
 
-		if err := srv.Shutdown(shutdownCtx); err != nil {
-			log.Printf("Ошибка при остановке сервера: %v\n", err)
+	return s.httpListener.Addr().String()
+}
+
+// GRPCAddr возвращает адрес, на котором слушает gRPC (после Start; "" - gRPC выключен)
+func (s *Server) GRPCAddr() string {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.grpcListener == nil {
+		return ""
+	}
+
+	return s.grpcListener.Addr().String()
+}
+
+// Errors возвращает канал, в который приходит ошибка, если сервер упал сам
+func (s *Server) Errors() <-chan error {
+	return s.errs
+}
+
+// Stop останавливает серверы: ждёт завершения текущих запросов, пока не истечёт ctx,
+// затем обрывает оставшиеся; недоставленные вебхуки остаются в outbox
+func (s *Server) Stop(ctx context.Context) error {
+
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		s.detach()
+		return nil
+	}
+	s.started = false
+	s.mu.Unlock()
+
+	s.health.Drain()
+
+	// gRPC останавливаем параллельно с HTTP и в пределах того же таймаута
+	grpcStopped := make(chan struct{})
+	go func() {
+		if s.grpcServer != nil {
+			s.grpcService.Close()
+			s.grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		err = fmt.Errorf("ошибка при остановке сервера: %w", err)
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		if s.grpcServer != nil {
+			s.grpcServer.Stop() // обрываем оставшиеся вызовы
+		}
+		<-grpcStopped
+	}
+
+	// запросы завершены - изменения общего хранилища остановленному серверу больше не нужны;
+	// недоставленное остаётся в outbox и уйдёт после перезапуска
+	s.detach()
+	s.stopTasks()
+	s.dispatcher.Wait()
+	s.tasks.Wait()
+
+	return err
+}
+
+// detach отписывает вебхуки и gRPC от изменений хранилища (повторный вызов ничего не делает)
+func (s *Server) detach() {
+
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	if s.grpcService != nil {
+		s.grpcService.Close()
+	}
+}
+
+// clientAuthTypes - значения config tls.client_auth
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":        tls.NoClientCert,
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// Run запускает сервер с настройками conf (см. config.Load) и останавливает его по SIGINT/SIGTERM
+func Run(db storage.Repository, conf config.Config) error {
+
+	cfg := Config{
+		Port:               conf.Server.Port,
+		GRPCPort:           conf.Server.GRPCPort,
+		WebhookOutbox:      conf.Server.WebhookOutbox,
+		IdempotencyWindow:  conf.Server.IdempotencyWindow,
+		ShutdownTimeout:    conf.Server.ShutdownTimeout,
+		DrainDelay:         conf.Server.DrainDelay,
+		TrashPurgeInterval: conf.Server.TrashPurgeInterval,
+		ReadHeaderTimeout:  conf.Server.ReadHeaderTimeout,
+		ReadTimeout:        conf.Server.ReadTimeout,
+		WriteTimeout:       conf.Server.WriteTimeout,
+		IdleTimeout:        conf.Server.IdleTimeout,
+		MaxBodySize:        conf.Server.MaxBodyBytes,
+		H2C:                conf.Server.H2C,
+		RateLimit: RateLimitConfig{
+			UserRate:   conf.Server.RateLimit.UserRate,
+			UserBurst:  conf.Server.RateLimit.UserBurst,
+			IPRate:     conf.Server.RateLimit.IPRate,
+			IPBurst:    conf.Server.RateLimit.IPBurst,
+			TrustProxy: conf.Server.RateLimit.TrustProxy,
+		},
+	}
+	if conf.Server.TLS.CertFile != "" {
+		cfg.TLS = &TLSConfig{
+			CertFile:      conf.Server.TLS.CertFile,
+			KeyFile:       conf.Server.TLS.KeyFile,
+			ClientCAFile:  conf.Server.TLS.ClientCAFile,
+			ClientAuth:    clientAuthTypes[conf.Server.TLS.ClientAuth],
+			ClientUsers:   conf.Server.TLS.ClientUsers,
+			CheckInterval: conf.Server.TLS.ReloadInterval,
 		}
+	}
+
+	// настраиваем логирование
+	logger, logFile, err := SetupLogging(conf.Log)
+	if err != nil {
+		return fmt.Errorf("ошибка настройки логирования: %w", err)
+	}
+	defer logFile.Close()
+	stopReopen := ReopenOnSIGHUP(logFile)
+	defer stopReopen()
+	cfg.Logger = logger
+	slog.SetDefault(logger) // стандартный log тоже пишет в журнал сервера
 
-		close(idleConnsClosed)
+	// трассировка (exporter: none, stdout, otlp, otlphttp)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    conf.Trace.Exporter,
+		Endpoint:    conf.Trace.Endpoint,
+		ServiceName: conf.Trace.ServiceName,
+		SampleRatio: conf.Trace.SampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// дописываем оставшиеся span, но не дольше нескольких секунд
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Ошибка остановки трассировки", "error", err)
+		}
 	}()
 
-	// запускаем сервер
-	logger.Printf("Сервер запущен на %s\n", srv.Addr)
+	srv, err := New(db, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := srv.Start(); err != nil {
+		return err
+	}
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigint)
+
+	var serveErr error
+	select {
+	case <-sigint:
+		logger.Info("Получен сигнал остановки сервера")
+
+		// сначала /readyz отвечает 503, чтобы балансировщик убрал сервер,
+		// и только потом закрываем порт (повторный сигнал - не ждём)
+		srv.Drain()
+		if cfg.DrainDelay > 0 {
+			logger.Info("Ожидание вывода сервера из балансировки", "delay", cfg.DrainDelay)
+			select {
+			case <-time.After(cfg.DrainDelay):
+			case <-sigint:
+			case serveErr = <-srv.Errors():
+			}
+		}
+	case serveErr = <-srv.Errors():
+		logger.Error("Сервер упал", "error", serveErr)
+	}
+
+	// останавливаем сервер (до окончания текущих соединений или таймаута)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return fmt.Errorf("ошибка сервера: %w", err)
+	if err := srv.Stop(shutdownCtx); err != nil {
+		logger.Error("Ошибка при остановке", "error", err)
+	}
+	if serveErr != nil {
+		return serveErr
 	}
 
-	// ждём остановки сервера
-	<-idleConnsClosed
-	logger.Println("Сервер корректно остановлен.")
+	logger.Info("Сервер корректно остановлен")
 
 	return nil
 }
+
+// purgeTrash периодически удаляет из корзины события с истёкшим сроком хранения
+func purgeTrash(ctx context.Context, db storage.Repository, interval time.Duration, logger *slog.Logger) {
+
+	ctxDB := storage.WithContext(db)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// отмена ctx при остановке сервера - не ошибка
+			if _, err := ctxDB.PurgeExpiredContext(ctx, now); err != nil && ctx.Err() == nil {
+				logger.Error("Ошибка очистки корзины", "error", err)
+			}
+		}
+	}
+}
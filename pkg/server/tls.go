@@ -0,0 +1,373 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// certCheckIntervalDefault - как часто проверять, не сменились ли файлы сертификатов
+const certCheckIntervalDefault = 30 * time.Second
+
+// TLSConfig - настройки TLS для HTTP и gRPC
+type TLSConfig struct {
+	CertFile      string             // сертификат сервера (PEM, можно с цепочкой)
+	KeyFile       string             // ключ сервера (PEM)
+	ClientCAFile  string             // CA для проверки сертификатов клиентов ("" - клиентов не проверяем)
+	ClientAuth    tls.ClientAuthType // требовать ли сертификат клиента (mTLS)
+	ClientUsers   map[string]int     // CN сертификата клиента -> ID пользователя (CN-число подходит и так)
+	CheckInterval time.Duration      // как часто проверять, не сменились ли файлы (0 - 30 секунд)
+}
+
+// certUserKey - ключ контекста для пользователя из проверенного сертификата клиента
+type certUserKey struct{}
+
+// certUserID возвращает пользователя, подтверждённого сертификатом клиента (0 - не подтверждён)
+func certUserID(ctx context.Context) int {
+
+	userID, _ := ctx.Value(certUserKey{}).(int)
+
+	return userID
+}
+
+// CertReloader держит сертификат сервера и CA клиентов и перечитывает их, когда файлы меняются,
+// без перезапуска сервера (проверка не чаще CheckInterval, при очередном подключении)
+type CertReloader struct {
+	cfg    TLSConfig
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  [3]time.Time // время изменения cert, key и CA при последней загрузке
+	checked   time.Time    // когда последний раз смотрели на файлы
+}
+
+// NewCertReloader загружает сертификаты; ошибка, если файлы не читаются
+func NewCertReloader(cfg TLSConfig, logger *slog.Logger) (*CertReloader, error) {
+
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = certCheckIntervalDefault
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	r := &CertReloader{cfg: cfg, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload перечитывает сертификат, ключ и CA клиентов (при ошибке остаются прежние)
+func (r *CertReloader) Reload() error {
+
+	modTimes := r.modTimesNow()
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки сертификата: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения CA клиентов: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("в %s нет сертификатов CA", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.checked = time.Now()
+
+	return nil
+}
+
+// modTimesNow возвращает время изменения файлов (нулевое - файла нет)
+func (r *CertReloader) modTimesNow() [3]time.Time {
+
+	var times [3]time.Time
+	for i, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+
+	return times
+}
+
+// maybeReload перечитывает файлы, если они изменились с прошлой загрузки
+func (r *CertReloader) maybeReload() {
+
+	r.mu.Lock()
+	if time.Since(r.checked) < r.cfg.CheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	changed := r.modTimesNow() != r.modTimes
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := r.Reload(); err != nil {
+		// сертификат могли записать наполовину - работаем со старым и попробуем при следующей проверке
+		r.logger.Error("Ошибка перезагрузки сертификатов", "error", err)
+		return
+	}
+	r.logger.Info("Сертификаты перезагружены", "cert", r.cfg.CertFile)
+}
+
+// GetCertificate отдаёт текущий сертификат сервера (для tls.Config)
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// TLSConfig собирает tls.Config, который на каждом подключении берёт актуальные сертификаты
+func (r *CertReloader) TLSConfig() *tls.Config {
+
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: r.GetCertificate,
+		ClientAuth:     r.cfg.ClientAuth,
+	}
+
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.maybeReload()
+
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		perConn := base.Clone()
+		perConn.ClientCAs = r.clientCAs
+		return perConn, nil
+	}
+
+	return config
+}
+
+// ClientCertMiddleware сопоставляет проверенный сертификат клиента пользователю и подставляет его ID
+// в X-Actor-ID (присланный клиентом заголовок перезаписывается); CN ищется в ClientUsers, иначе CN должен быть
+// ID пользователя; сертификат, который не удалось сопоставить, и запрос к событиям другого пользователя
+// (ID в пути, ?user_id или user_id в теле, которое читается не дальше maxBodySize) - 403;
+// при включённой проверке клиентов X-Actor-ID запроса без сертификата отбрасывается
+func ClientCertMiddleware(cfg TLSConfig, maxBodySize int64) func(http.Handler) http.Handler {
+
+	if maxBodySize == 0 {
+		maxBodySize = rateLimitPeekDefault // тот же лимит, что по умолчанию у хэндлеров API
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			var answer api.Answer
+
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				if cfg.ClientAuth != tls.NoClientCert {
+					r.Header.Del(api.HeaderActorID)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, err := certUser(r.TLS.VerifiedChains[0][0], cfg.ClientUsers)
+			if err != nil {
+				answer.Error = err.Error()
+				api.WriterJSON(w, http.StatusForbidden, answer) // 403
+				return
+			}
+			if foreign := foreignUserID(r, userID, maxBodySize); foreign != 0 {
+				answer.Error = fmt.Sprintf("сертификат клиента выдан пользователю %d, а запрос к пользователю %d", userID, foreign)
+				api.WriterJSON(w, http.StatusForbidden, answer) // 403
+				return
+			}
+			r.Header.Set(api.HeaderActorID, strconv.Itoa(userID))
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), certUserKey{}, userID)))
+		})
+	}
+}
+
+// foreignUserID возвращает пользователя запроса, отличного от userID (0 - все совпадают или не указаны):
+// ID из пути v2, параметр user_id и user_id в теле v1, в том числе у операций пакета
+func foreignUserID(r *http.Request, userID int, maxBodySize int64) int {
+
+	ids := []int{pathUserID(r.URL.Path)}
+	if id, err := strconv.Atoi(r.URL.Query().Get("user_id")); err == nil {
+		ids = append(ids, id)
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		ids = append(ids, bodyUserIDs(peekBody(r, maxBodySize))...)
+	}
+
+	for _, id := range ids {
+		if id != 0 && id != userID {
+			return id
+		}
+	}
+
+	return 0
+}
+
+// ClientCertUnaryInterceptor - то же сопоставление сертификата клиента пользователю для gRPC:
+// user_id запроса (у UpdateEvent - события) должен совпадать с пользователем сертификата, пустой
+// заменяется им, actor_id перезаписывается; без сертификата при включённой проверке actor_id сбрасывается
+func ClientCertUnaryInterceptor(cfg TLSConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		userID, err := grpcCertUser(ctx, cfg.ClientUsers)
+		if err != nil {
+			return nil, err
+		}
+		if err := bindCertUser(req, userID, cfg.ClientAuth != tls.NoClientCert); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// ClientCertStreamInterceptor - ClientCertUnaryInterceptor для потоковых вызовов (проверяется каждое сообщение клиента)
+func ClientCertStreamInterceptor(cfg TLSConfig) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		userID, err := grpcCertUser(stream.Context(), cfg.ClientUsers)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &certStream{ServerStream: stream, userID: userID, clientAuth: cfg.ClientAuth != tls.NoClientCert})
+	}
+}
+
+// certStream подставляет пользователя сертификата в сообщения потока
+type certStream struct {
+	grpc.ServerStream
+	userID     int
+	clientAuth bool
+}
+
+// RecvMsg читает сообщение клиента и проверяет в нём пользователя
+func (s *certStream) RecvMsg(m any) error {
+
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return bindCertUser(m, s.userID, s.clientAuth)
+}
+
+// grpcCertUser возвращает пользователя из проверенного сертификата клиента gRPC (0 - сертификата нет)
+func grpcCertUser(ctx context.Context, users map[string]int) (int, error) {
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return 0, nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return 0, nil
+	}
+
+	userID, err := certUser(info.State.VerifiedChains[0][0], users)
+	if err != nil {
+		return 0, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return userID, nil
+}
+
+// bindCertUser подставляет пользователя сертификата в запрос gRPC по полям user_id и actor_id
+// (userID 0 - сертификата нет: actor_id сбрасывается, если проверка клиентов включена)
+func bindCertUser(req any, userID int, clientAuth bool) error {
+
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+
+	if actor := fields.ByName("actor_id"); actor != nil && (userID != 0 || clientAuth) {
+		m.Set(actor, protoreflect.ValueOfInt64(int64(userID)))
+	}
+	if userID == 0 {
+		return nil
+	}
+
+	// у UpdateEvent пользователь - внутри события
+	owner := m
+	if fields.ByName("user_id") == nil {
+		event := fields.ByName("event")
+		if event == nil || event.Message() == nil || !m.Has(event) {
+			return nil
+		}
+		owner = m.Mutable(event).Message()
+	}
+	field := owner.Descriptor().Fields().ByName("user_id")
+	if field == nil {
+		return nil
+	}
+
+	switch id := owner.Get(field).Int(); id {
+	case 0:
+		owner.Set(field, protoreflect.ValueOfInt64(int64(userID)))
+	case int64(userID):
+	default:
+		return status.Errorf(codes.PermissionDenied, "сертификат клиента выдан пользователю %d, а запрос к пользователю %d", userID, id)
+	}
+
+	return nil
+}
+
+// certUser возвращает ID пользователя для сертификата клиента
+func certUser(cert *x509.Certificate, users map[string]int) (int, error) {
+
+	name := cert.Subject.CommonName
+	if userID, ok := users[name]; ok {
+		return userID, nil
+	}
+	if userID, err := strconv.Atoi(name); err == nil && userID > 0 {
+		return userID, nil
+	}
+
+	return 0, fmt.Errorf("сертификат клиента %q не сопоставлен пользователю", name)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import "time"
+
+// ChangeType - тип изменения в хранилище
+type ChangeType string
+
+const (
+	ChangeCreated  ChangeType = "event.created"  // событие создано
+	ChangeUpdated  ChangeType = "event.updated"  // событие обновлено
+	ChangeDeleted  ChangeType = "event.deleted"  // событие удалено (перемещено в корзину)
+	ChangeRestored ChangeType = "event.restored" // событие восстановлено из корзины
+	ChangePurged   ChangeType = "event.purged"   // событие удалено из корзины навсегда
+)
+
+// ChangeTypes возвращает все известные типы изменений
+func ChangeTypes() []ChangeType {
+	return []ChangeType{ChangeCreated, ChangeUpdated, ChangeDeleted, ChangeRestored, ChangePurged}
+}
+
+// Change описывает одно изменение событий в хранилище
+type Change struct {
+	Seq     int64      `json:"seq"`      // сквозной номер изменения
+	Type    ChangeType `json:"type"`     // тип изменения
+	UserID  int        `json:"user_id"`  // id пользователя
+	EventID int        `json:"event_id"` // id события
+	Event   *Event     `json:"event"`    // состояние события после изменения (для удаления - последнее)
+	Time    time.Time  `json:"time"`     // время изменения
+}
+
+// Listener получает уведомления об изменениях
+// (вызывается синхронно после снятия блокировки хранилища, поэтому не должен надолго блокироваться)
+type Listener func(change Change)
+
+// newChange формирует изменение с копией события, чтобы подписчики не гонялись с хранилищем
+func newChange(changeType ChangeType, event *Event) *Change {
+
+	eventCopy := *event
+
+	return &Change{
+		Type:    changeType,
+		UserID:  event.UserID,
+		EventID: event.ID,
+		Event:   &eventCopy,
+		Time:    time.Now(),
+	}
+}
+
+// Subscribe добавляет подписчика на изменения хранилища и возвращает функцию отписки
+// (повторный вызов отписки ничего не делает)
+func (s *Storage) Subscribe(listener Listener) func() {
+
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+
+	entry := &listener
+	s.listeners = append(s.listeners, entry)
+
+	return func() {
+		s.listenersMu.Lock()
+		defer s.listenersMu.Unlock()
+
+		for i, current := range s.listeners {
+			if current == entry {
+				s.listeners = append(s.listeners[:i:i], s.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify рассылает изменение подписчикам (nil - изменений не было)
+func (s *Storage) notify(change *Change) {
+
+	if change == nil {
+		return
+	}
+
+	s.listenersMu.RLock()
+	defer s.listenersMu.RUnlock()
+
+	for _, listener := range s.listeners {
+		(*listener)(*change)
+	}
+}
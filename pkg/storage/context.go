@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// ContextRepository - методы Repository с context.Context: отмена запроса или истёкший дедлайн
+// прерывают ожидание хранилища (ошибка - ctx.Err())
+type ContextRepository interface {
+	CreateContext(ctx context.Context, userID int, date time.Time, title, content string) (int, error)
+	UpdateContext(ctx context.Context, event *Event) error
+	UpdateByContext(ctx context.Context, actorID int, event *Event) error
+	DeleteContext(ctx context.Context, userID, eventID int) error
+	DeleteIfMatchContext(ctx context.Context, userID, eventID, version int) error
+	GetContext(ctx context.Context, userID, eventID int) (*Event, error)
+	GetForDayContext(ctx context.Context, userID int, date time.Time) ([]*Event, error)
+	GetForWeekContext(ctx context.Context, userID int, date time.Time) ([]*Event, error)
+	GetForMonthContext(ctx context.Context, userID int, date time.Time) ([]*Event, error)
+	SyncContext(ctx context.Context, userID int, token string) (*SyncResult, error)
+	TrashContext(ctx context.Context, userID int) ([]*TrashedEvent, error)
+	RestoreContext(ctx context.Context, userID, eventID int) (*Event, error)
+	PurgeContext(ctx context.Context, userID, eventID int) error
+	PurgeExpiredContext(ctx context.Context, now time.Time) (int, error)
+	HistoryContext(ctx context.Context, userID, eventID int) ([]*Revision, error)
+	RevisionContext(ctx context.Context, userID, eventID, number int) (*Revision, error)
+	RevertContext(ctx context.Context, userID, eventID, number, actorID int) (*Event, error)
+}
+
+// WithContext возвращает хранилище с методами *Context: само db, если оно их реализует,
+// иначе обёртку, которая проверяет ctx перед каждым вызовом
+func WithContext(db Repository) ContextRepository {
+
+	if ctxDB, ok := db.(ContextRepository); ok {
+		return ctxDB
+	}
+
+	return contextAdapter{db: db}
+}
+
+// lockContext берёт Mu на запись или возвращает ctx.Err(), если ctx отменили раньше
+func (s *Storage) lockContext(ctx context.Context) error {
+	return acquire(ctx, s.Mu.TryLock, s.Mu.Lock, s.Mu.Unlock)
+}
+
+// rlockContext берёт Mu на чтение или возвращает ctx.Err(), если ctx отменили раньше
+func (s *Storage) rlockContext(ctx context.Context) error {
+	return acquire(ctx, s.Mu.TryRLock, s.Mu.RLock, s.Mu.RUnlock)
+}
+
+// Ping проверяет, что хранилище не заблокировано намертво: берёт Mu на чтение и сразу отпускает
+func (s *Storage) Ping(ctx context.Context) error {
+
+	if err := s.rlockContext(ctx); err != nil {
+		return err
+	}
+	s.Mu.RUnlock()
+
+	return nil
+}
+
+// acquire ждёт блокировку, пока не отменён ctx
+// (sync.RWMutex не умеет отменять ожидание, поэтому ждём в горутине:
+// если ctx отменили раньше, она снимет блокировку сразу, как только получит)
+func acquire(ctx context.Context, tryLock func() bool, lock, unlock func()) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tryLock() {
+		return nil
+	}
+	if ctx.Done() == nil {
+		lock() // context.Background() не отменяется - ждём как обычно
+		return nil
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			unlock()
+		}()
+		return ctx.Err()
+	}
+}
+
+// contextAdapter добавляет методы *Context хранилищу, которое их не реализует
+type contextAdapter struct {
+	db Repository
+}
+
+func (a contextAdapter) CreateContext(ctx context.Context, userID int, date time.Time, title, content string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.db.Create(userID, date, title, content)
+}
+
+func (a contextAdapter) UpdateContext(ctx context.Context, event *Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.db.Update(event)
+}
+
+func (a contextAdapter) UpdateByContext(ctx context.Context, actorID int, event *Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.db.UpdateBy(actorID, event)
+}
+
+func (a contextAdapter) DeleteContext(ctx context.Context, userID, eventID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.db.Delete(userID, eventID)
+}
+
+func (a contextAdapter) DeleteIfMatchContext(ctx context.Context, userID, eventID, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.db.DeleteIfMatch(userID, eventID, version)
+}
+
+func (a contextAdapter) GetContext(ctx context.Context, userID, eventID int) (*Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.Get(userID, eventID)
+}
+
+func (a contextAdapter) GetForDayContext(ctx context.Context, userID int, date time.Time) ([]*Event, error) {
+	if err := ctx.Err(); err != nil {
+		return []*Event{}, err
+	}
+	return a.db.GetForDay(userID, date)
+}
+
+func (a contextAdapter) GetForWeekContext(ctx context.Context, userID int, date time.Time) ([]*Event, error) {
+	if err := ctx.Err(); err != nil {
+		return []*Event{}, err
+	}
+	return a.db.GetForWeek(userID, date)
+}
+
+func (a contextAdapter) GetForMonthContext(ctx context.Context, userID int, date time.Time) ([]*Event, error) {
+	if err := ctx.Err(); err != nil {
+		return []*Event{}, err
+	}
+	return a.db.GetForMonth(userID, date)
+}
+
+func (a contextAdapter) SyncContext(ctx context.Context, userID int, token string) (*SyncResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.Sync(userID, token)
+}
+
+func (a contextAdapter) TrashContext(ctx context.Context, userID int) ([]*TrashedEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.Trash(userID)
+}
+
+func (a contextAdapter) RestoreContext(ctx context.Context, userID, eventID int) (*Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.Restore(userID, eventID)
+}
+
+func (a contextAdapter) PurgeContext(ctx context.Context, userID, eventID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.db.Purge(userID, eventID)
+}
+
+func (a contextAdapter) PurgeExpiredContext(ctx context.Context, now time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.db.PurgeExpired(now)
+}
+
+func (a contextAdapter) HistoryContext(ctx context.Context, userID, eventID int) ([]*Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.History(userID, eventID)
+}
+
+func (a contextAdapter) RevisionContext(ctx context.Context, userID, eventID, number int) (*Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.Revision(userID, eventID, number)
+}
+
+func (a contextAdapter) RevertContext(ctx context.Context, userID, eventID, number, actorID int) (*Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.db.Revert(userID, eventID, number, actorID)
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// FieldChange описывает изменение одного поля события
+type FieldChange struct {
+	Field string      `json:"field"`         // имя поля (как в JSON)
+	Old   interface{} `json:"old,omitempty"` // значение до изменения
+	New   interface{} `json:"new,omitempty"` // значение после изменения
+}
+
+// Revision описывает одну версию события
+type Revision struct {
+	Number  int           `json:"revision"` // номер версии (1 - создание)
+	EventID int           `json:"event_id"` // id события
+	ActorID int           `json:"actor_id"` // кто внёс изменение
+	Time    time.Time     `json:"time"`     // когда внесено изменение
+	Event   *Event        `json:"event"`    // как событие выглядело после изменения
+	Diff    []FieldChange `json:"diff"`     // что поменялось относительно предыдущей версии
+}
+
+// diffEvents сравнивает редактируемые поля двух состояний события
+func diffEvents(before, after *Event) []FieldChange {
+
+	diff := make([]FieldChange, 0)
+
+	if before == nil {
+		before = &Event{}
+	}
+
+	if !before.Date.Equal(after.Date) {
+		diff = append(diff, FieldChange{Field: "date", Old: nullableDate(before.Date), New: nullableDate(after.Date)})
+	}
+	if before.Title != after.Title {
+		diff = append(diff, FieldChange{Field: "title", Old: before.Title, New: after.Title})
+	}
+	if before.Content != after.Content {
+		diff = append(diff, FieldChange{Field: "content", Old: before.Content, New: after.Content})
+	}
+
+	return diff
+}
+
+// nullableDate не даёт нулевой дате попасть в diff как "0001-01-01"
+func nullableDate(t time.Time) interface{} {
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
+
+// addRevision записывает новую версию события (вызывается под s.Mu)
+func (s *Storage) addRevision(actorID int, before, after *Event) {
+
+	if s.history == nil {
+		s.history = make(map[int][]*Revision)
+	}
+
+	snapshot := *after
+	s.history[after.ID] = append(s.history[after.ID], &Revision{
+		Number:  len(s.history[after.ID]) + 1,
+		EventID: after.ID,
+		ActorID: actorID,
+		Time:    time.Now(),
+		Event:   &snapshot,
+		Diff:    diffEvents(before, after),
+	})
+}
+
+// revisions возвращает историю события, проверяя, что оно принадлежит пользователю (вызывается под s.Mu)
+func (s *Storage) revisions(userID, eventID int) ([]*Revision, error) {
+
+	revisions, ok := s.history[eventID]
+	if !ok || len(revisions) == 0 || revisions[0].Event.UserID != userID {
+		return nil, notFound("история события с %d не найдена", eventID)
+	}
+
+	return revisions, nil
+}
+
+// History возвращает все версии события пользователя (от старых к новым)
+func (s *Storage) History(userID, eventID int) ([]*Revision, error) {
+	return s.HistoryContext(context.Background(), userID, eventID)
+}
+
+// HistoryContext - History с отменой через ctx
+func (s *Storage) HistoryContext(ctx context.Context, userID, eventID int) (_ []*Revision, err error) {
+
+	defer s.observe("History", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.RUnlock()
+
+	revisions, err := s.revisions(userID, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, copyRevision(revision))
+	}
+
+	return result, nil
+}
+
+// Revision возвращает конкретную версию события пользователя
+func (s *Storage) Revision(userID, eventID, number int) (*Revision, error) {
+	return s.RevisionContext(context.Background(), userID, eventID, number)
+}
+
+// RevisionContext - Revision с отменой через ctx
+func (s *Storage) RevisionContext(ctx context.Context, userID, eventID, number int) (_ *Revision, err error) {
+
+	defer s.observe("Revision", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.RUnlock()
+
+	return s.revision(userID, eventID, number)
+}
+
+// revision ищет версию события без блокировки (вызывается под s.Mu), возвращает копию
+func (s *Storage) revision(userID, eventID, number int) (*Revision, error) {
+
+	revisions, err := s.revisions(userID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if number <= 0 || number > len(revisions) {
+		return nil, notFound("версия %d события с %d не найдена", number, eventID)
+	}
+
+	return copyRevision(revisions[number-1]), nil
+}
+
+// Revert возвращает событие к состоянию указанной версии от имени actorID
+// (откат сам записывается в историю новой версией)
+func (s *Storage) Revert(userID, eventID, number, actorID int) (*Event, error) {
+	return s.RevertContext(context.Background(), userID, eventID, number, actorID)
+}
+
+// RevertContext - Revert с отменой через ctx
+func (s *Storage) RevertContext(ctx context.Context, userID, eventID, number, actorID int) (_ *Event, err error) {
+
+	defer s.observe("Revert", time.Now(), &err)
+
+	var change *Change
+	defer func() { s.notify(change) }()
+
+	// чтение версии, откат и результат - под одной блокировкой и одним вызовом в метриках
+	if err = s.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.Unlock()
+
+	revision, err := s.revision(userID, eventID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	// откат безусловный: версия снимка заведомо устарела
+	event := revision.Event
+	event.Version = 0
+	if change, err = s.updateBy(actorID, event); err != nil {
+		return nil, err
+	}
+
+	return s.get(userID, eventID)
+}
+
+// copyRevision отдаёт копию версии, чтобы вызывающий не мог изменить историю
+func copyRevision(revision *Revision) *Revision {
+
+	revisionCopy := *revision
+	snapshot := *revision.Event
+	revisionCopy.Event = &snapshot
+	revisionCopy.Diff = append([]FieldChange(nil), revision.Diff...)
+
+	return &revisionCopy
+}
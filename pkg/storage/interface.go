@@ -1,6 +1,9 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Event описывает запись в календаре событий
 type Event struct {
@@ -9,14 +12,49 @@ type Event struct {
 	Date    time.Time `json:"date"`              // дата события
 	Title   string    `json:"title"`             // заголовок события
 	Content string    `json:"content,omitempty"` // содержание события
+	Version int       `json:"version"`           // версия события (растёт при каждом обновлении)
+
+	CreatedAt time.Time `json:"created_at"` // когда событие создано (заполняет хранилище)
+	UpdatedAt time.Time `json:"updated_at"` // когда событие последний раз менялось (заполняет хранилище)
 }
 
 // Repository - интерфейс, реализующий требуемые методы
 type Repository interface {
 	Create(userID int, date time.Time, title, content string) (int, error) // добавляет event в хранилище, возвращает ID event или ошибку
-	Update(event *Event) error                                             // обновляет event в хранилище, возвращает ошибку, если событие не найдено
-	Delete(userID, eventID int) error                                      // удаляет event из хранилища, возвращает ошибку, если событие не найдено
+	Update(event *Event) error                                             // обновляет event в хранилище (если Version != 0 - только при совпадении версии), при успехе записывает в event сохранённое состояние с новой версией
+	UpdateBy(actorID int, event *Event) error                              // то же, что Update, но в историю пишется actorID как автор изменения
+	Delete(userID, eventID int) error                                      // перемещает event в корзину, возвращает ошибку, если событие не найдено
+	DeleteIfMatch(userID, eventID, version int) error                      // то же, что Delete, но только если версия совпадает (0 - без проверки)
+	Get(userID, eventID int) (*Event, error)                               // возвращает событие пользователя или ошибку, если оно не найдено
 	GetForDay(userID int, date time.Time) ([]*Event, error)                // возвращает перечень событий на день или ошибку
 	GetForWeek(userID int, date time.Time) ([]*Event, error)               // возвращает перечень событий на неделю или ошибку
 	GetForMonth(userID int, date time.Time) ([]*Event, error)              // возвращает перечень событий на месяц или ошибку
+	Sync(userID int, token string) (*SyncResult, error)                    // возвращает изменения после токена синхронизации или ошибку
+	Trash(userID int) ([]*TrashedEvent, error)                             // возвращает содержимое корзины пользователя или ошибку
+	Restore(userID, eventID int) (*Event, error)                           // возвращает событие из корзины с прежним ID или ошибку
+	Purge(userID, eventID int) error                                       // удаляет событие из корзины навсегда, возвращает ошибку, если его там нет
+	PurgeExpired(now time.Time) (int, error)                               // удаляет из корзины просроченные события, возвращает их количество
+	History(userID, eventID int) ([]*Revision, error)                      // возвращает все версии события или ошибку
+	Revision(userID, eventID, number int) (*Revision, error)               // возвращает конкретную версию события или ошибку
+	Revert(userID, eventID, number, actorID int) (*Event, error)           // возвращает событие к указанной версии или ошибку
+}
+
+// Transactional и Tx (расширение для атомарных пакетов операций) описаны в tx.go
+
+// ContextRepository (те же методы с context.Context) описан в context.go
+
+// Notifier - хранилище, умеющее сообщать об изменениях событий
+type Notifier interface {
+	Subscribe(listener Listener) func() // добавляет подписчика на изменения, возвращает функцию отписки
+}
+
+// Observable - хранилище, умеющее сообщать о выполненных операциях и своём размере (для метрик)
+type Observable interface {
+	Observe(observer Observer) // добавляет наблюдателя за операциями
+	Stats() Stats              // возвращает количество пользователей и событий
+}
+
+// Pinger - хранилище, умеющее проверить, что оно доступно (для проверки готовности /readyz)
+type Pinger interface {
+	Ping(ctx context.Context) error // nil - хранилище отвечает
 }
@@ -0,0 +1,60 @@
+package storage
+
+import "time"
+
+// Observer получает имя метода Repository, длительность вызова и его ошибку
+// (вызывается после снятия блокировки; составные методы вроде Revert учитываются целиком
+// и вместе с вложенными вызовами)
+type Observer func(method string, duration time.Duration, err error)
+
+// Stats - размер хранилища
+type Stats struct {
+	Users   int // пользователей, у которых есть события
+	Events  int // событий (без корзины)
+	Trashed int // событий в корзине
+}
+
+// Observe добавляет наблюдателя за операциями хранилища
+func (s *Storage) Observe(observer Observer) {
+
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+
+	s.observers = append(s.observers, observer)
+}
+
+// observe сообщает наблюдателям о завершённой операции (вызывается через defer)
+func (s *Storage) observe(method string, start time.Time, err *error) {
+
+	s.listenersMu.RLock()
+	defer s.listenersMu.RUnlock()
+
+	if len(s.observers) == 0 {
+		return
+	}
+
+	duration := time.Since(start)
+	for _, observer := range s.observers {
+		observer(method, duration, *err)
+	}
+}
+
+// Stats возвращает количество пользователей и событий в хранилище
+func (s *Storage) Stats() Stats {
+
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+
+	var stats Stats
+	for _, events := range s.Events {
+		if len(events) > 0 {
+			stats.Users++
+		}
+		stats.Events += len(events)
+	}
+	for _, items := range s.trash {
+		stats.Trashed += len(items)
+	}
+
+	return stats
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+var (
+	// ErrQuotaExceeded возвращается, если у пользователя уже MaxEventsPerUser событий
+	ErrQuotaExceeded = errors.New("превышена квота событий пользователя")
+	// ErrTooLarge возвращается, если title или content длиннее MaxTitleLength или MaxContentLength
+	ErrTooLarge = errors.New("слишком длинное поле события")
+)
+
+// checkQuota проверяет, что пользователю можно добавить ещё одно событие (вызывается под s.Mu)
+func (s *Storage) checkQuota(userID int) error {
+
+	if s.MaxEventsPerUser > 0 && len(s.Events[userID]) >= s.MaxEventsPerUser {
+		return fmt.Errorf("%w: не больше %d событий", ErrQuotaExceeded, s.MaxEventsPerUser)
+	}
+
+	return nil
+}
+
+// checkLength проверяет длину title и content в символах
+func (s *Storage) checkLength(title, content string) error {
+
+	if s.MaxTitleLength > 0 && utf8.RuneCountInString(title) > s.MaxTitleLength {
+		return fmt.Errorf("%w: title не длиннее %d символов", ErrTooLarge, s.MaxTitleLength)
+	}
+	if s.MaxContentLength > 0 && utf8.RuneCountInString(content) > s.MaxContentLength {
+		return fmt.Errorf("%w: content не длиннее %d символов", ErrTooLarge, s.MaxContentLength)
+	}
+
+	return nil
+}
@@ -1,16 +1,58 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrNotFound - общий признак ошибок "не найдено" (проверяется через errors.Is)
+	ErrNotFound = errors.New("не найдено")
+	// ErrVersionConflict возвращается, если событие успели изменить после того, как клиент его прочитал
+	ErrVersionConflict = errors.New("версия события не совпадает")
+)
+
+// notFoundError - ошибка "не найдено" с собственным текстом
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string { return e.msg }
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrNotFound)
+func (e *notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// notFound формирует ошибку "не найдено" с текстом по формату
+func notFound(format string, args ...interface{}) error {
+	return &notFoundError{msg: fmt.Sprintf(format, args...)}
+}
+
 // Storage используем для хранения информации календаря событий
 type Storage struct {
 	Mu     sync.RWMutex     // предполагаем конкурентный доступ к ресурсу
 	Events map[int][]*Event // user_id -> events
 	NextID int              // номер (ID) следующего Event (счётчик событий)
+
+	ChangeLogLimit int           // сколько изменений хранить на пользователя для синхронизации (0 - по умолчанию)
+	TrashRetention time.Duration // сколько удалённые события лежат в корзине (0 - по умолчанию)
+
+	MaxEventsPerUser int // сколько событий может быть у пользователя, больше - ErrQuotaExceeded (0 - без ограничения)
+	MaxTitleLength   int // наибольшая длина title в символах, больше - ErrTooLarge (0 - без ограничения)
+	MaxContentLength int // наибольшая длина content в символах, больше - ErrTooLarge (0 - без ограничения)
+
+	listenersMu sync.RWMutex // отдельный мьютекс, чтобы уведомлять подписчиков вне Mu
+	listeners   []*Listener  // подписчики на изменения хранилища (указатель - чтобы найти подписчика при отписке)
+	observers   []Observer   // наблюдатели за длительностью операций (метрики)
+
+	seq       int64            // номер последнего изменения (сквозной по всем пользователям)
+	changeLog map[int][]Change // user_id -> журнал изменений
+	compacted map[int]int64    // user_id -> номер последнего выброшенного из журнала изменения
+
+	trash   map[int][]*TrashedEvent // user_id -> удалённые события
+	history map[int][]*Revision     // event_id -> версии события
 }
 
 // NewStorage создаёт новое хранилище
@@ -23,16 +65,42 @@ func NewStorage() *Storage {
 
 // Create добавляет event в хранилище, возвращает ID event или ошибку
 func (s *Storage) Create(userID int, date time.Time, title, content string) (int, error) {
+	return s.CreateContext(context.Background(), userID, date, title, content)
+}
+
+// CreateContext - Create с отменой через ctx
+func (s *Storage) CreateContext(ctx context.Context, userID int, date time.Time, title, content string) (_ int, err error) {
 
-	s.Mu.Lock()
+	defer s.observe("Create", time.Now(), &err)
+
+	var change *Change
+	defer func() { s.notify(change) }() // уведомляем уже после снятия блокировки
+
+	if err = s.lockContext(ctx); err != nil {
+		return 0, err
+	}
 	defer s.Mu.Unlock()
 
+	id, change, err := s.create(userID, date, title, content)
+
+	return id, err
+}
+
+// create добавляет event без блокировки (вызывается под s.Mu), возвращает ID и изменение
+func (s *Storage) create(userID int, date time.Time, title, content string) (int, *Change, error) {
+
 	// выполняем базовые проверки
 	if userID < 0 {
-		return 0, fmt.Errorf("ошибочный ID пользователя")
+		return 0, nil, fmt.Errorf("ошибочный ID пользователя")
 	}
 	if title == "" {
-		return 0, fmt.Errorf("поле title должно быть заполнено")
+		return 0, nil, fmt.Errorf("поле title должно быть заполнено")
+	}
+	if err := s.checkLength(title, content); err != nil {
+		return 0, nil, err
+	}
+	if err := s.checkQuota(userID); err != nil {
+		return 0, nil, err
 	}
 
 	// проверяем, что память под слайс событий есть и пользователь существует
@@ -41,77 +109,223 @@ func (s *Storage) Create(userID int, date time.Time, title, content string) (int
 	}
 
 	// добавляем пользователю событие в список
-	s.Events[userID] = append(s.Events[userID], &Event{
-		ID:      s.NextID,
-		UserID:  userID,
-		Date:    date,
-		Title:   title,
-		Content: content,
-	})
+	now := time.Now()
+	event := &Event{
+		ID:        s.NextID,
+		UserID:    userID,
+		Date:      date,
+		Title:     title,
+		Content:   content,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.Events[userID] = append(s.Events[userID], event)
 	// добаляем счётчик событий
 	s.NextID++
 
-	return s.NextID - 1, nil
+	change := s.logChange(newChange(ChangeCreated, event))
+	s.addRevision(userID, nil, event)
+
+	return s.NextID - 1, change, nil
 }
 
 // Update обновляет event в хранилище, возвращает ошибку, если событие не найдено
+// (изменение записывается в историю от имени владельца события;
+// если event.Version != 0, обновление пройдёт только при совпадении версии)
 func (s *Storage) Update(event *Event) error {
+	return s.UpdateContext(context.Background(), event)
+}
 
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
+// UpdateContext - Update с отменой через ctx
+func (s *Storage) UpdateContext(ctx context.Context, event *Event) (err error) {
+
+	defer s.observe("Update", time.Now(), &err)
 
 	if event == nil {
 		return fmt.Errorf("событие не может быть nil")
 	}
 
+	return s.updateByContext(ctx, event.UserID, event)
+}
+
+// UpdateBy обновляет event от имени пользователя actorID, возвращает ошибку, если событие не найдено
+func (s *Storage) UpdateBy(actorID int, event *Event) error {
+	return s.UpdateByContext(context.Background(), actorID, event)
+}
+
+// UpdateByContext - UpdateBy с отменой через ctx
+func (s *Storage) UpdateByContext(ctx context.Context, actorID int, event *Event) (err error) {
+
+	defer s.observe("UpdateBy", time.Now(), &err)
+
+	return s.updateByContext(ctx, actorID, event)
+}
+
+// updateByContext берёт блокировку, обновляет event и рассылает изменение
+// (без учёта в метриках: вызов уже учитывает публичный метод)
+func (s *Storage) updateByContext(ctx context.Context, actorID int, event *Event) (err error) {
+
+	var change *Change
+	defer func() { s.notify(change) }()
+
+	if err = s.lockContext(ctx); err != nil {
+		return err
+	}
+	defer s.Mu.Unlock()
+
+	change, err = s.updateBy(actorID, event)
+
+	return err
+}
+
+// updateBy обновляет event без блокировки (вызывается под s.Mu), возвращает изменение
+// и заполняет event сохранённым состоянием
+func (s *Storage) updateBy(actorID int, event *Event) (*Change, error) {
+
+	if event == nil {
+		return nil, fmt.Errorf("событие не может быть nil")
+	}
+	if err := s.checkLength(event.Title, event.Content); err != nil {
+		return nil, err
+	}
+
 	events, ok := s.Events[event.UserID]
 	if !ok {
-		return fmt.Errorf("пользователь с %d не найден", event.UserID)
+		return nil, notFound("пользователь с %d не найден", event.UserID)
 	}
 	if events == nil {
-		return fmt.Errorf("у пользователя с %d событий ваще не найдено", event.UserID)
+		return nil, notFound("у пользователя с %d событий ваще не найдено", event.UserID)
 	}
 
 	for i := 0; i < len(events); i++ {
 		// если нашли событие - обновляем данные
 		if event.ID == events[i].ID {
+			// проверка версии под той же блокировкой, что и запись
+			if event.Version != 0 && event.Version != events[i].Version {
+				return nil, fmt.Errorf("%w: ожидалась %d, текущая %d", ErrVersionConflict, event.Version, events[i].Version)
+			}
+			before := *events[i]
 			events[i].Date = event.Date
 			events[i].Title = event.Title
 			events[i].Content = event.Content
-			return nil
+			events[i].Version++
+			events[i].UpdatedAt = time.Now()
+			change := s.logChange(newChange(ChangeUpdated, events[i]))
+			s.addRevision(actorID, &before, events[i])
+			// отдаём записанное состояние под той же блокировкой: повторное чтение
+			// могло бы вернуть уже чужую, более позднюю версию
+			*event = *events[i]
+			return change, nil
 		}
 	}
 
 	// если событие не найдено - что-то пошло не так
-	return fmt.Errorf("событие с %d не найдено", event.ID)
+	return nil, notFound("событие с %d не найдено", event.ID)
 }
 
-// Delete удаляет event из хранилища, возвращает ошибку, если событие не найдено
+// Delete перемещает event в корзину, возвращает ошибку, если событие не найдено
 func (s *Storage) Delete(userID, eventID int) error {
+	return s.DeleteContext(context.Background(), userID, eventID)
+}
+
+// DeleteContext - Delete с отменой через ctx
+func (s *Storage) DeleteContext(ctx context.Context, userID, eventID int) (err error) {
+
+	defer s.observe("Delete", time.Now(), &err)
+
+	return s.deleteIfMatchContext(ctx, userID, eventID, 0)
+}
+
+// DeleteIfMatch перемещает event в корзину, только если его версия равна version (0 - без проверки)
+func (s *Storage) DeleteIfMatch(userID, eventID, version int) error {
+	return s.DeleteIfMatchContext(context.Background(), userID, eventID, version)
+}
+
+// DeleteIfMatchContext - DeleteIfMatch с отменой через ctx
+func (s *Storage) DeleteIfMatchContext(ctx context.Context, userID, eventID, version int) (err error) {
+
+	defer s.observe("DeleteIfMatch", time.Now(), &err)
+
+	return s.deleteIfMatchContext(ctx, userID, eventID, version)
+}
+
+// deleteIfMatchContext берёт блокировку, перемещает event в корзину и рассылает изменение
+// (без учёта в метриках: вызов уже учитывает публичный метод)
+func (s *Storage) deleteIfMatchContext(ctx context.Context, userID, eventID, version int) (err error) {
+
+	var change *Change
+	defer func() { s.notify(change) }()
 
-	s.Mu.Lock()
+	if err = s.lockContext(ctx); err != nil {
+		return err
+	}
 	defer s.Mu.Unlock()
 
+	change, err = s.deleteIfMatch(userID, eventID, version)
+
+	return err
+}
+
+// deleteIfMatch перемещает event в корзину без блокировки (вызывается под s.Mu), возвращает изменение
+func (s *Storage) deleteIfMatch(userID, eventID, version int) (*Change, error) {
+
 	events, ok := s.Events[userID]
 	if !ok {
-		return fmt.Errorf("пользователь с %d не найден", userID)
+		return nil, notFound("пользователь с %d не найден", userID)
 	}
 	if events == nil {
-		return fmt.Errorf("у пользователя с %d событий ваще не найдено", userID)
+		return nil, notFound("у пользователя с %d событий ваще не найдено", userID)
 	}
 
 	for i := 0; i < len(events); i++ {
 		// если нашли событие - удаляем событие
 		if eventID == events[i].ID {
+			if version != 0 && version != events[i].Version {
+				return nil, fmt.Errorf("%w: ожидалась %d, текущая %d", ErrVersionConflict, version, events[i].Version)
+			}
+			change := s.logChange(newChange(ChangeDeleted, events[i]))
+			s.moveToTrash(events[i])
 			copy(events[i:], events[i+1:])
 			s.Events[userID] = events[:len(events)-1]
 			// или s.Events[userID] = slices.Delete(s.Events[userID], i, i+1)
-			return nil
+			return change, nil
 		}
 	}
 
 	// если событие не найдено - что-то пошло не так
-	return fmt.Errorf("событие с %d не найдено", eventID)
+	return nil, notFound("событие с %d не найдено", eventID)
+}
+
+// Get возвращает копию события пользователя, возвращает ошибку, если событие не найдено
+func (s *Storage) Get(userID, eventID int) (*Event, error) {
+	return s.GetContext(context.Background(), userID, eventID)
+}
+
+// GetContext - Get с отменой через ctx
+func (s *Storage) GetContext(ctx context.Context, userID, eventID int) (_ *Event, err error) {
+
+	defer s.observe("Get", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.RUnlock()
+
+	return s.get(userID, eventID)
+}
+
+// get ищет событие без блокировки (вызывается под s.Mu), возвращает копию
+func (s *Storage) get(userID, eventID int) (*Event, error) {
+
+	for _, event := range s.Events[userID] {
+		if event.ID == eventID {
+			eventCopy := *event
+			return &eventCopy, nil
+		}
+	}
+
+	return nil, notFound("событие с %d не найдено", eventID)
 }
 
 // dayNormalizer возвращает начало дня
@@ -122,16 +336,25 @@ func dayNormalizer(t time.Time) time.Time {
 
 // возвращает перечень событий на день или ошибку
 func (s *Storage) GetForDay(userID int, date time.Time) ([]*Event, error) {
+	return s.GetForDayContext(context.Background(), userID, date)
+}
 
-	s.Mu.RLock()
+// GetForDayContext - GetForDay с отменой через ctx
+func (s *Storage) GetForDayContext(ctx context.Context, userID int, date time.Time) (_ []*Event, err error) {
+
+	defer s.observe("GetForDay", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return []*Event{}, err
+	}
 	defer s.Mu.RUnlock()
 
 	events, ok := s.Events[userID]
 	if !ok {
-		return []*Event{}, fmt.Errorf("пользователь с %d не найден", userID)
+		return []*Event{}, notFound("пользователь с %d не найден", userID)
 	}
 	if events == nil {
-		return []*Event{}, fmt.Errorf("у пользователя с %d событий ваще не найдено", userID)
+		return []*Event{}, notFound("у пользователя с %d событий ваще не найдено", userID)
 	}
 
 	eventsForDay := make([]*Event, 0)
@@ -163,16 +386,25 @@ func weekNormalizer(t time.Time) time.Time {
 
 // возвращает перечень событий на неделю или ошибку
 func (s *Storage) GetForWeek(userID int, date time.Time) ([]*Event, error) {
+	return s.GetForWeekContext(context.Background(), userID, date)
+}
+
+// GetForWeekContext - GetForWeek с отменой через ctx
+func (s *Storage) GetForWeekContext(ctx context.Context, userID int, date time.Time) (_ []*Event, err error) {
+
+	defer s.observe("GetForWeek", time.Now(), &err)
 
-	s.Mu.RLock()
+	if err = s.rlockContext(ctx); err != nil {
+		return []*Event{}, err
+	}
 	defer s.Mu.RUnlock()
 
 	events, ok := s.Events[userID]
 	if !ok {
-		return []*Event{}, fmt.Errorf("пользователь с %d не найден", userID)
+		return []*Event{}, notFound("пользователь с %d не найден", userID)
 	}
 	if events == nil {
-		return []*Event{}, fmt.Errorf("у пользователя с %d событий ваще не найдено", userID)
+		return []*Event{}, notFound("у пользователя с %d событий ваще не найдено", userID)
 	}
 
 	eventsForWeek := make([]*Event, 0)
@@ -196,16 +428,25 @@ func monthNormalizer(t time.Time) time.Time {
 
 // возвращает перечень событий на месяц или ошибку
 func (s *Storage) GetForMonth(userID int, date time.Time) ([]*Event, error) {
+	return s.GetForMonthContext(context.Background(), userID, date)
+}
+
+// GetForMonthContext - GetForMonth с отменой через ctx
+func (s *Storage) GetForMonthContext(ctx context.Context, userID int, date time.Time) (_ []*Event, err error) {
 
-	s.Mu.RLock()
+	defer s.observe("GetForMonth", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return []*Event{}, err
+	}
 	defer s.Mu.RUnlock()
 
 	events, ok := s.Events[userID]
 	if !ok {
-		return []*Event{}, fmt.Errorf("пользователь с %d не найден", userID)
+		return []*Event{}, notFound("пользователь с %d не найден", userID)
 	}
 	if events == nil {
-		return []*Event{}, fmt.Errorf("у пользователя с %d событий ваще не найдено", userID)
+		return []*Event{}, notFound("у пользователя с %d событий ваще не найдено", userID)
 	}
 
 	eventsForMonth := make([]*Event, 0)
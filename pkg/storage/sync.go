@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultChangeLogLimit - сколько изменений на пользователя хранится, если не задано иное
+const defaultChangeLogLimit = 1000
+
+var (
+	ErrInvalidSyncToken = errors.New("неверный токен синхронизации")
+	ErrSyncTokenExpired = errors.New("токен синхронизации устарел, требуется полная синхронизация")
+)
+
+// SyncResult - ответ на запрос синхронизации
+type SyncResult struct {
+	Events    []*Event `json:"events"`     // созданные или изменённые события (при полной синхронизации - все)
+	Deleted   []int    `json:"deleted"`    // ID удалённых событий (tombstones)
+	SyncToken string   `json:"sync_token"` // токен для следующего запроса
+	Full      bool     `json:"full"`       // true - это полный снимок, локальные данные надо заменить
+}
+
+// logChange присваивает изменению номер и кладёт его в журнал пользователя (вызывается под s.Mu)
+func (s *Storage) logChange(change *Change) *Change {
+
+	if s.changeLog == nil {
+		s.changeLog = make(map[int][]Change)
+	}
+	if s.compacted == nil {
+		s.compacted = make(map[int]int64)
+	}
+
+	s.seq++
+	change.Seq = s.seq
+	s.changeLog[change.UserID] = append(s.changeLog[change.UserID], *change)
+
+	// уплотняем журнал: старые записи выбрасываем, запоминая, до какого номера журнал неполон
+	limit := s.ChangeLogLimit
+	if limit <= 0 {
+		limit = defaultChangeLogLimit
+	}
+	if log := s.changeLog[change.UserID]; len(log) > limit {
+		drop := len(log) - limit
+		s.compacted[change.UserID] = log[drop-1].Seq
+		s.changeLog[change.UserID] = append([]Change(nil), log[drop:]...)
+	}
+
+	return change
+}
+
+// Sync возвращает изменения событий пользователя после токена
+// (пустой токен - полный снимок, устаревший токен - ErrSyncTokenExpired)
+func (s *Storage) Sync(userID int, token string) (*SyncResult, error) {
+	return s.SyncContext(context.Background(), userID, token)
+}
+
+// SyncContext - Sync с отменой через ctx
+func (s *Storage) SyncContext(ctx context.Context, userID int, token string) (_ *SyncResult, err error) {
+
+	defer s.observe("Sync", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.RUnlock()
+
+	if userID <= 0 {
+		return nil, fmt.Errorf("ошибочный ID пользователя")
+	}
+
+	result := &SyncResult{
+		Events:    make([]*Event, 0),
+		Deleted:   make([]int, 0),
+		SyncToken: encodeSyncToken(userID, s.seq),
+	}
+
+	// первая синхронизация - отдаём всё, что есть
+	if token == "" {
+		result.Full = true
+		for _, event := range s.Events[userID] {
+			eventCopy := *event
+			result.Events = append(result.Events, &eventCopy)
+		}
+		return result, nil
+	}
+
+	since, err := decodeSyncToken(userID, token)
+	if err != nil {
+		return nil, err
+	}
+	if since > s.seq {
+		return nil, ErrInvalidSyncToken
+	}
+	if since < s.compacted[userID] {
+		return nil, ErrSyncTokenExpired
+	}
+
+	// схлопываем журнал: по каждому событию важно только последнее изменение
+	last := make(map[int]Change)
+	order := make([]int, 0)
+	for _, change := range s.changeLog[userID] {
+		if change.Seq <= since {
+			continue
+		}
+		if _, ok := last[change.EventID]; !ok {
+			order = append(order, change.EventID)
+		}
+		last[change.EventID] = change
+	}
+
+	for _, eventID := range order {
+		change := last[eventID]
+		if change.Type == ChangeDeleted {
+			result.Deleted = append(result.Deleted, eventID)
+			continue
+		}
+		eventCopy := *change.Event
+		result.Events = append(result.Events, &eventCopy)
+	}
+
+	return result, nil
+}
+
+// encodeSyncToken упаковывает позицию в журнале в непрозрачную строку
+func encodeSyncToken(userID int, seq int64) string {
+
+	raw := fmt.Sprintf("%d:%d", userID, seq)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeSyncToken достаёт позицию в журнале и проверяет, что токен выдан этому пользователю
+func decodeSyncToken(userID int, token string) (int64, error) {
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidSyncToken
+	}
+
+	owner, seqStr, ok := strings.Cut(string(raw), ":")
+	if !ok || owner != strconv.Itoa(userID) {
+		return 0, ErrInvalidSyncToken
+	}
+
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+
+	return seq, nil
+}
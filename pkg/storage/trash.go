@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// defaultTrashRetention - сколько удалённые события хранятся, если не задано иное
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// TrashedEvent описывает событие в корзине
+type TrashedEvent struct {
+	Event     *Event    `json:"event"`      // событие в том виде, в каком его удалили
+	DeletedAt time.Time `json:"deleted_at"` // время удаления
+	ExpiresAt time.Time `json:"expires_at"` // после этого момента событие будет удалено навсегда
+}
+
+// moveToTrash кладёт событие в корзину пользователя (вызывается под s.Mu)
+func (s *Storage) moveToTrash(event *Event) {
+
+	if s.trash == nil {
+		s.trash = make(map[int][]*TrashedEvent)
+	}
+
+	retention := s.TrashRetention
+	if retention <= 0 {
+		retention = defaultTrashRetention
+	}
+
+	now := time.Now()
+	s.trash[event.UserID] = append(s.trash[event.UserID], &TrashedEvent{
+		Event:     event,
+		DeletedAt: now,
+		ExpiresAt: now.Add(retention),
+	})
+}
+
+// takeFromTrash вынимает событие из корзины пользователя (вызывается под s.Mu)
+func (s *Storage) takeFromTrash(userID, eventID int) (*TrashedEvent, error) {
+
+	trashed := s.trash[userID]
+	for i := 0; i < len(trashed); i++ {
+		if trashed[i].Event.ID == eventID {
+			item := trashed[i]
+			copy(trashed[i:], trashed[i+1:])
+			s.trash[userID] = trashed[:len(trashed)-1]
+			return item, nil
+		}
+	}
+
+	return nil, notFound("событие с %d в корзине не найдено", eventID)
+}
+
+// Trash возвращает содержимое корзины пользователя
+func (s *Storage) Trash(userID int) ([]*TrashedEvent, error) {
+	return s.TrashContext(context.Background(), userID)
+}
+
+// TrashContext - Trash с отменой через ctx
+func (s *Storage) TrashContext(ctx context.Context, userID int) (_ []*TrashedEvent, err error) {
+
+	defer s.observe("Trash", time.Now(), &err)
+
+	if err = s.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.RUnlock()
+
+	items := make([]*TrashedEvent, 0, len(s.trash[userID]))
+	for _, item := range s.trash[userID] {
+		eventCopy := *item.Event
+		items = append(items, &TrashedEvent{
+			Event:     &eventCopy,
+			DeletedAt: item.DeletedAt,
+			ExpiresAt: item.ExpiresAt,
+		})
+	}
+
+	return items, nil
+}
+
+// Restore возвращает событие из корзины с прежним ID
+func (s *Storage) Restore(userID, eventID int) (*Event, error) {
+	return s.RestoreContext(context.Background(), userID, eventID)
+}
+
+// RestoreContext - Restore с отменой через ctx
+func (s *Storage) RestoreContext(ctx context.Context, userID, eventID int) (_ *Event, err error) {
+
+	defer s.observe("Restore", time.Now(), &err)
+
+	var change *Change
+	defer func() { s.notify(change) }()
+
+	if err = s.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Mu.Unlock()
+
+	// восстановленное событие снова занимает место в квоте
+	if err = s.checkQuota(userID); err != nil {
+		return nil, err
+	}
+
+	item, err := s.takeFromTrash(userID, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Events[userID] = append(s.Events[userID], item.Event)
+	change = s.logChange(newChange(ChangeRestored, item.Event))
+
+	eventCopy := *item.Event
+	return &eventCopy, nil
+}
+
+// Purge удаляет событие из корзины навсегда
+func (s *Storage) Purge(userID, eventID int) error {
+	return s.PurgeContext(context.Background(), userID, eventID)
+}
+
+// PurgeContext - Purge с отменой через ctx
+func (s *Storage) PurgeContext(ctx context.Context, userID, eventID int) (err error) {
+
+	defer s.observe("Purge", time.Now(), &err)
+
+	var change *Change
+	defer func() { s.notify(change) }()
+
+	if err = s.lockContext(ctx); err != nil {
+		return err
+	}
+	defer s.Mu.Unlock()
+
+	item, err := s.takeFromTrash(userID, eventID)
+	if err != nil {
+		return err
+	}
+
+	// в журнал синхронизации не пишем - клиенты уже получили tombstone при удалении
+	change = newChange(ChangePurged, item.Event)
+	delete(s.history, eventID)
+
+	return nil
+}
+
+// PurgeExpired удаляет навсегда события, срок хранения которых в корзине истёк к моменту now,
+// возвращает количество удалённых
+func (s *Storage) PurgeExpired(now time.Time) (int, error) {
+	return s.PurgeExpiredContext(context.Background(), now)
+}
+
+// PurgeExpiredContext - PurgeExpired с отменой через ctx
+func (s *Storage) PurgeExpiredContext(ctx context.Context, now time.Time) (_ int, err error) {
+
+	defer s.observe("PurgeExpired", time.Now(), &err)
+
+	var changes []*Change
+	defer func() {
+		for _, change := range changes {
+			s.notify(change)
+		}
+	}()
+
+	if err = s.lockContext(ctx); err != nil {
+		return 0, err
+	}
+	defer s.Mu.Unlock()
+
+	for userID, trashed := range s.trash {
+		kept := trashed[:0]
+		for _, item := range trashed {
+			if item.ExpiresAt.After(now) {
+				kept = append(kept, item)
+				continue
+			}
+			changes = append(changes, newChange(ChangePurged, item.Event))
+			delete(s.history, item.Event.ID)
+		}
+		s.trash[userID] = kept
+	}
+
+	return len(changes), nil
+}
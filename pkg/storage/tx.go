@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Tx - операции, доступные внутри транзакции
+type Tx interface {
+	Create(userID int, date time.Time, title, content string) (int, error) // добавляет event, возвращает ID или ошибку
+	UpdateBy(actorID int, event *Event) error                              // обновляет event (с проверкой версии, если она задана)
+	DeleteIfMatch(userID, eventID, version int) error                      // перемещает event в корзину (с проверкой версии, если она задана)
+	Get(userID, eventID int) (*Event, error)                               // возвращает событие с учётом изменений транзакции
+}
+
+// Transactional - расширение Repository: несколько операций применяются атомарно
+// (если fn вернула ошибку, ни одно изменение не сохраняется)
+type Transactional interface {
+	Atomic(fn func(tx Tx) error) error
+	AtomicContext(ctx context.Context, fn func(tx Tx) error) error // то же, но ожидание транзакции прерывается отменой ctx
+}
+
+// storageTx выполняет операции без блокировки - её держит Atomic
+type storageTx struct {
+	s       *Storage
+	changes []*Change // изменения, о которых сообщим подписчикам после фиксации
+
+	// журнал отката: состояние только тех пользователей и историй, которые трогает транзакция
+	undo         []func()
+	savedUsers   map[int]bool // user_id, состояние которых уже в журнале
+	savedHistory map[int]bool // event_id, история которых уже в журнале
+	nextID       int
+	seq          int64
+}
+
+// newStorageTx начинает транзакцию (вызывается под s.Mu)
+func newStorageTx(s *Storage) *storageTx {
+	return &storageTx{
+		s:            s,
+		savedUsers:   make(map[int]bool),
+		savedHistory: make(map[int]bool),
+		nextID:       s.NextID,
+		seq:          s.seq,
+	}
+}
+
+func (tx *storageTx) Create(userID int, date time.Time, title, content string) (int, error) {
+
+	tx.saveUser(userID)
+	tx.saveHistory(tx.s.NextID)
+
+	id, change, err := tx.s.create(userID, date, title, content)
+	if err == nil {
+		tx.changes = append(tx.changes, change)
+	}
+
+	return id, err
+}
+
+func (tx *storageTx) UpdateBy(actorID int, event *Event) error {
+
+	if event != nil {
+		tx.saveUser(event.UserID)
+		tx.saveHistory(event.ID)
+	}
+
+	change, err := tx.s.updateBy(actorID, event)
+	if err == nil {
+		tx.changes = append(tx.changes, change)
+	}
+
+	return err
+}
+
+func (tx *storageTx) DeleteIfMatch(userID, eventID, version int) error {
+
+	tx.saveUser(userID)
+
+	change, err := tx.s.deleteIfMatch(userID, eventID, version)
+	if err == nil {
+		tx.changes = append(tx.changes, change)
+	}
+
+	return err
+}
+
+func (tx *storageTx) Get(userID, eventID int) (*Event, error) {
+	return tx.s.get(userID, eventID)
+}
+
+// Atomic выполняет fn под одной блокировкой хранилища:
+// при ошибке (или панике) изменения транзакции откатываются, подписчики ничего не узнают
+func (s *Storage) Atomic(fn func(tx Tx) error) error {
+	return s.AtomicContext(context.Background(), fn)
+}
+
+// AtomicContext - Atomic с отменой через ctx (пока транзакция ждёт блокировку)
+func (s *Storage) AtomicContext(ctx context.Context, fn func(tx Tx) error) (err error) {
+
+	defer s.observe("Atomic", time.Now(), &err)
+
+	var tx *storageTx
+	committed := false
+	defer func() {
+		if committed {
+			for _, change := range tx.changes {
+				s.notify(change)
+			}
+		}
+	}()
+
+	if err = s.lockContext(ctx); err != nil {
+		return err
+	}
+	defer s.Mu.Unlock()
+
+	tx = newStorageTx(s)
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+// saveUser записывает в журнал отката события, журнал изменений и корзину пользователя
+// перед первым изменением в транзакции (вызывается под s.Mu)
+func (tx *storageTx) saveUser(userID int) {
+
+	if tx.savedUsers[userID] {
+		return
+	}
+	tx.savedUsers[userID] = true
+
+	s := tx.s
+	// события меняются на месте, а удаление сдвигает слайс - копируем и указатели, и значения
+	events, hasEvents := s.Events[userID]
+	pointers := append([]*Event(nil), events...)
+	values := make([]Event, len(events))
+	for i, event := range events {
+		values[i] = *event
+	}
+	if events == nil {
+		pointers = nil
+	}
+	// журнал и корзина внутри транзакции только дописываются, достаточно прежней длины
+	log, hasLog := s.changeLog[userID]
+	compacted, hasCompacted := s.compacted[userID]
+	trash, hasTrash := s.trash[userID]
+
+	tx.undo = append(tx.undo, func() {
+		for i, event := range pointers {
+			*event = values[i]
+		}
+		restoreKey(s.Events, userID, pointers, hasEvents)
+		restoreKey(s.changeLog, userID, log, hasLog)
+		restoreKey(s.compacted, userID, compacted, hasCompacted)
+		restoreKey(s.trash, userID, trash, hasTrash)
+	})
+}
+
+// saveHistory записывает в журнал отката историю события перед первым изменением в транзакции
+// (вызывается под s.Mu; версии не меняются после записи, достаточно прежней длины)
+func (tx *storageTx) saveHistory(eventID int) {
+
+	if tx.savedHistory[eventID] {
+		return
+	}
+	tx.savedHistory[eventID] = true
+
+	s := tx.s
+	revisions, ok := s.history[eventID]
+	tx.undo = append(tx.undo, func() {
+		restoreKey(s.history, eventID, revisions, ok)
+	})
+}
+
+// rollback возвращает всё, что изменила транзакция (вызывается под s.Mu)
+func (tx *storageTx) rollback() {
+
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.s.NextID = tx.nextID
+	tx.s.seq = tx.seq
+}
+
+// restoreKey возвращает прежнее значение ключа (ключа не было - удаляет его)
+func restoreKey[V any](m map[int]V, key int, value V, existed bool) {
+
+	if existed {
+		m[key] = value
+		return
+	}
+	delete(m, key)
+}
@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// repository - хранилище, каждый вызов которого становится дочерним span переданного ctx
+type repository struct {
+	db storage.ContextRepository
+}
+
+// Repository оборачивает хранилище: вызовы записываются span "storage.<Метод>" внутри span из ctx
+func Repository(db storage.ContextRepository) storage.ContextRepository {
+	return &repository{db: db}
+}
+
+// start открывает span вызова хранилища
+func (r *repository) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+
+	return Tracer().Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// end отмечает ошибку и закрывает span
+func end(span trace.Span, err error) {
+	recordError(span, err)
+	span.End()
+}
+
+func (r *repository) CreateContext(ctx context.Context, userID int, date time.Time, title, content string) (int, error) {
+
+	ctx, span := r.start(ctx, "Create", userAttr(userID))
+	id, err := r.db.CreateContext(ctx, userID, date, title, content)
+	span.SetAttributes(eventAttr(id))
+	end(span, err)
+
+	return id, err
+}
+
+func (r *repository) UpdateContext(ctx context.Context, event *storage.Event) error {
+
+	ctx, span := r.start(ctx, "Update")
+	if event != nil {
+		span.SetAttributes(userAttr(event.UserID), eventAttr(event.ID))
+	}
+	err := r.db.UpdateContext(ctx, event)
+	end(span, err)
+
+	return err
+}
+
+func (r *repository) UpdateByContext(ctx context.Context, actorID int, event *storage.Event) error {
+
+	ctx, span := r.start(ctx, "UpdateBy", attribute.Int("calendar.actor_id", actorID))
+	if event != nil {
+		span.SetAttributes(userAttr(event.UserID), eventAttr(event.ID))
+	}
+	err := r.db.UpdateByContext(ctx, actorID, event)
+	end(span, err)
+
+	return err
+}
+
+func (r *repository) DeleteContext(ctx context.Context, userID, eventID int) error {
+
+	ctx, span := r.start(ctx, "Delete", userAttr(userID), eventAttr(eventID))
+	err := r.db.DeleteContext(ctx, userID, eventID)
+	end(span, err)
+
+	return err
+}
+
+func (r *repository) DeleteIfMatchContext(ctx context.Context, userID, eventID, version int) error {
+
+	ctx, span := r.start(ctx, "DeleteIfMatch", userAttr(userID), eventAttr(eventID))
+	err := r.db.DeleteIfMatchContext(ctx, userID, eventID, version)
+	end(span, err)
+
+	return err
+}
+
+func (r *repository) GetContext(ctx context.Context, userID, eventID int) (*storage.Event, error) {
+
+	ctx, span := r.start(ctx, "Get", userAttr(userID), eventAttr(eventID))
+	event, err := r.db.GetContext(ctx, userID, eventID)
+	end(span, err)
+
+	return event, err
+}
+
+func (r *repository) GetForDayContext(ctx context.Context, userID int, date time.Time) ([]*storage.Event, error) {
+
+	ctx, span := r.start(ctx, "GetForDay", userAttr(userID))
+	events, err := r.db.GetForDayContext(ctx, userID, date)
+	end(span, err)
+
+	return events, err
+}
+
+func (r *repository) GetForWeekContext(ctx context.Context, userID int, date time.Time) ([]*storage.Event, error) {
+
+	ctx, span := r.start(ctx, "GetForWeek", userAttr(userID))
+	events, err := r.db.GetForWeekContext(ctx, userID, date)
+	end(span, err)
+
+	return events, err
+}
+
+func (r *repository) GetForMonthContext(ctx context.Context, userID int, date time.Time) ([]*storage.Event, error) {
+
+	ctx, span := r.start(ctx, "GetForMonth", userAttr(userID))
+	events, err := r.db.GetForMonthContext(ctx, userID, date)
+	end(span, err)
+
+	return events, err
+}
+
+func (r *repository) SyncContext(ctx context.Context, userID int, token string) (*storage.SyncResult, error) {
+
+	ctx, span := r.start(ctx, "Sync", userAttr(userID))
+	result, err := r.db.SyncContext(ctx, userID, token)
+	end(span, err)
+
+	return result, err
+}
+
+func (r *repository) TrashContext(ctx context.Context, userID int) ([]*storage.TrashedEvent, error) {
+
+	ctx, span := r.start(ctx, "Trash", userAttr(userID))
+	items, err := r.db.TrashContext(ctx, userID)
+	end(span, err)
+
+	return items, err
+}
+
+func (r *repository) RestoreContext(ctx context.Context, userID, eventID int) (*storage.Event, error) {
+
+	ctx, span := r.start(ctx, "Restore", userAttr(userID), eventAttr(eventID))
+	event, err := r.db.RestoreContext(ctx, userID, eventID)
+	end(span, err)
+
+	return event, err
+}
+
+func (r *repository) PurgeContext(ctx context.Context, userID, eventID int) error {
+
+	ctx, span := r.start(ctx, "Purge", userAttr(userID), eventAttr(eventID))
+	err := r.db.PurgeContext(ctx, userID, eventID)
+	end(span, err)
+
+	return err
+}
+
+func (r *repository) PurgeExpiredContext(ctx context.Context, now time.Time) (int, error) {
+
+	ctx, span := r.start(ctx, "PurgeExpired")
+	purged, err := r.db.PurgeExpiredContext(ctx, now)
+	span.SetAttributes(attribute.Int("calendar.purged", purged))
+	end(span, err)
+
+	return purged, err
+}
+
+func (r *repository) HistoryContext(ctx context.Context, userID, eventID int) ([]*storage.Revision, error) {
+
+	ctx, span := r.start(ctx, "History", userAttr(userID), eventAttr(eventID))
+	revisions, err := r.db.HistoryContext(ctx, userID, eventID)
+	end(span, err)
+
+	return revisions, err
+}
+
+func (r *repository) RevisionContext(ctx context.Context, userID, eventID, number int) (*storage.Revision, error) {
+
+	ctx, span := r.start(ctx, "Revision", userAttr(userID), eventAttr(eventID))
+	revision, err := r.db.RevisionContext(ctx, userID, eventID, number)
+	end(span, err)
+
+	return revision, err
+}
+
+func (r *repository) RevertContext(ctx context.Context, userID, eventID, number, actorID int) (*storage.Event, error) {
+
+	ctx, span := r.start(ctx, "Revert", userAttr(userID), eventAttr(eventID))
+	event, err := r.db.RevertContext(ctx, userID, eventID, number, actorID)
+	end(span, err)
+
+	return event, err
+}
@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName - имя, под которым сервер создаёт свои span
+const instrumentationName = "github.com/IPampurin/calendar-server"
+
+const (
+	ExporterNone     = "none"     // трассировка выключена
+	ExporterStdout   = "stdout"   // span пишутся в Writer (для локальной отладки)
+	ExporterOTLP     = "otlp"     // OTLP по gRPC (по умолчанию localhost:4317)
+	ExporterOTLPHTTP = "otlphttp" // OTLP по HTTP (по умолчанию localhost:4318)
+)
+
+// Config - настройки трассировки
+type Config struct {
+	Exporter    string    // none, stdout, otlp или otlphttp ("" - none)
+	Endpoint    string    // адрес коллектора, например http://localhost:4317 ("" - из OTEL_EXPORTER_OTLP_ENDPOINT или по умолчанию)
+	ServiceName string    // service.name в ресурсе ("" - calendar-server)
+	SampleRatio float64   // доля трассируемых запросов без родительского span от 0 (ни одного) до 1 (все); меньше нуля - все
+	Writer      io.Writer // куда пишет stdout-экспортёр (nil - os.Stdout)
+}
+
+// Setup настраивает глобальный TracerProvider и распространение контекста W3C (traceparent, baggage),
+// возвращает функцию, которая дописывает оставшиеся span и останавливает экспортёр
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+
+	// контекст трассировки пробрасываем, даже если свои span не экспортируем
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case "", ExporterNone:
+		return noop, nil
+	case ExporterStdout:
+		writer := cfg.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("неизвестный экспортёр трассировки %q, используйте none, stdout, otlp или otlphttp", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки экспортёра трассировки: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "calendar-server"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки ресурса трассировки: %w", err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	switch {
+	case cfg.SampleRatio == 0:
+		sampler = sdktrace.NeverSample() // свои span не пишем, но продолжаем трассы с родительским span
+	case cfg.SampleRatio > 0 && cfg.SampleRatio < 1:
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает tracer сервера из глобального TracerProvider
+// (пока Setup не вызван - ничего не записывающий)
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// TraceID возвращает ID трассировки из контекста ("" - запрос не трассируется)
+func TraceID(ctx context.Context) string {
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
+
+// statusRecorder запоминает статус ответа
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap даёт http.ResponseController добраться до исходного ResponseWriter
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Middleware открывает span на каждый HTTP-запрос, продолжая трассировку из traceparent
+// (имя span уточняет RouteMiddleware, когда известен маршрут)
+func Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// RouteMiddleware называет span запроса по шаблону маршрута ServeMux ("GET /event/{id}")
+// (ставится прямо над роутером: шаблон виден только в запросе, который роутер получил)
+func RouteMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		next.ServeHTTP(w, r)
+
+		if r.Pattern == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Pattern)
+		if _, route, found := strings.Cut(r.Pattern, " "); found {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+	})
+}
+
+// recordError отмечает span ошибкой (nil - ничего не делает)
+func recordError(span trace.Span, err error) {
+
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// userAttr - атрибут с ID пользователя
+func userAttr(userID int) attribute.KeyValue {
+	return attribute.Int("calendar.user_id", userID)
+}
+
+// eventAttr - атрибут с ID события
+func eventAttr(eventID int) attribute.KeyValue {
+	return attribute.Int("calendar.event_id", eventID)
+}
@@ -0,0 +1,623 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// ErrNotFound возвращается, если подписка не найдена
+var ErrNotFound = errors.New("подписка не найдена")
+
+// значения конфигурации по умолчанию
+const (
+	defaultMaxAttempts  = 8
+	defaultBaseBackoff  = time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+	defaultPollInterval = time.Second
+	defaultHistoryLimit = 100
+	defaultTimeout      = 10 * time.Second
+	defaultWorkers      = 4
+)
+
+// Config - настройки диспетчера вебхуков
+type Config struct {
+	Path         string        // файл для хранения подписок и outbox ("" - только в памяти)
+	Client       *http.Client  // HTTP-клиент для доставки
+	MaxAttempts  int           // сколько раз пытаться доставить
+	BaseBackoff  time.Duration // пауза перед второй попыткой (дальше удваивается)
+	MaxBackoff   time.Duration // максимальная пауза между попытками
+	PollInterval time.Duration // как часто просматривать outbox и сбрасывать результаты попыток на диск
+	HistoryLimit int           // сколько завершённых доставок хранить на подписку
+	Workers      int           // сколько подписок обслуживать одновременно
+}
+
+// state - подписки и outbox в памяти
+type state struct {
+	NextSubscriptionID int
+	NextDeliveryID     int
+	Subscriptions      []*Subscription
+	Deliveries         []*Delivery
+}
+
+// stateFile - то, что сохраняется на диск (в отличие от ответов API, с секретами подписок:
+// без них после перезапуска нечем подписывать доставки; файл доступен только владельцу)
+type stateFile struct {
+	NextSubscriptionID int                   `json:"next_subscription_id"`
+	NextDeliveryID     int                   `json:"next_delivery_id"`
+	Subscriptions      []*storedSubscription `json:"subscriptions"`
+	Deliveries         []*Delivery           `json:"deliveries"`
+}
+
+// storedSubscription - подписка в файле вместе с секретом
+type storedSubscription struct {
+	Subscription
+	Secret string `json:"secret"`
+}
+
+// Dispatcher хранит подписки и доставляет изменения с повторами из outbox
+type Dispatcher struct {
+	cfg Config
+
+	mu    sync.Mutex
+	state state
+	dirty bool // state изменился после последней записи на диск
+
+	saveMu sync.Mutex // записи файла идут в том же порядке, что и снимки состояния
+
+	busy    map[int]bool   // подписки, которым сейчас идёт доставка (под d.mu)
+	workers sync.WaitGroup // отправки, запущенные фоновой доставкой
+
+	wake chan struct{}  // сигнал воркеру, что в outbox появилась работа
+	wg   sync.WaitGroup // ожидание воркера при остановке
+}
+
+// New создаёт диспетчер и загружает сохранённое состояние
+func New(cfg Config) (*Dispatcher, error) {
+
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = defaultBaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.HistoryLimit <= 0 {
+		cfg.HistoryLimit = defaultHistoryLimit
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+
+	d := &Dispatcher{
+		cfg:   cfg,
+		state: state{NextSubscriptionID: 1, NextDeliveryID: 1},
+		busy:  make(map[int]bool),
+		wake:  make(chan struct{}, 1),
+	}
+
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// load читает состояние из файла, если он есть
+func (d *Dispatcher) load() error {
+
+	if d.cfg.Path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(d.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать outbox вебхуков: %w", err)
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("не удалось разобрать outbox вебхуков: %w", err)
+	}
+
+	d.state = state{
+		NextSubscriptionID: file.NextSubscriptionID,
+		NextDeliveryID:     file.NextDeliveryID,
+		Deliveries:         file.Deliveries,
+	}
+	for _, stored := range file.Subscriptions {
+		sub := stored.Subscription
+		sub.Secret = stored.Secret
+		d.state.Subscriptions = append(d.state.Subscriptions, &sub)
+	}
+
+	return nil
+}
+
+// Flush записывает накопленные изменения на диск, если они есть
+// (новые доставки Notify сохраняет сразу, результаты попыток фоновая доставка
+// сбрасывает не чаще PollInterval и при остановке)
+func (d *Dispatcher) Flush() error {
+
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	d.mu.Lock()
+	if !d.dirty || d.cfg.Path == "" {
+		d.dirty = false
+		d.mu.Unlock()
+		return nil
+	}
+	data, err := d.marshalState()
+	d.dirty = false
+	d.mu.Unlock()
+
+	if err == nil {
+		err = d.writeState(data)
+	}
+	if err != nil {
+		// попробуем ещё раз при следующем сбросе
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+	}
+
+	return err
+}
+
+// marshalState сериализует состояние вместе с секретами подписок (вызывается под d.mu)
+func (d *Dispatcher) marshalState() ([]byte, error) {
+
+	file := stateFile{
+		NextSubscriptionID: d.state.NextSubscriptionID,
+		NextDeliveryID:     d.state.NextDeliveryID,
+		Subscriptions:      make([]*storedSubscription, 0, len(d.state.Subscriptions)),
+		Deliveries:         d.state.Deliveries,
+	}
+	for _, sub := range d.state.Subscriptions {
+		file.Subscriptions = append(file.Subscriptions, &storedSubscription{Subscription: *sub, Secret: sub.Secret})
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать outbox вебхуков: %w", err)
+	}
+
+	return data, nil
+}
+
+// writeState атомарно записывает сериализованное состояние на диск
+func (d *Dispatcher) writeState(data []byte) error {
+
+	if err := os.MkdirAll(filepath.Dir(d.cfg.Path), 0755); err != nil {
+		return fmt.Errorf("не удалось создать папку для outbox: %w", err)
+	}
+
+	// пишем во временный файл и переименовываем, чтобы не оставить полузаписанный outbox
+	tmp := d.cfg.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("не удалось записать outbox вебхуков: %w", err)
+	}
+	if err := os.Rename(tmp, d.cfg.Path); err != nil {
+		return fmt.Errorf("не удалось сохранить outbox вебхуков: %w", err)
+	}
+
+	return nil
+}
+
+// AddSubscription регистрирует подписку и возвращает её с заполненными ID и секретом
+func (d *Dispatcher) AddSubscription(sub Subscription) (*Subscription, error) {
+
+	if err := sub.validate(); err != nil {
+		return nil, err
+	}
+	if sub.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+
+	d.mu.Lock()
+	sub.ID = d.state.NextSubscriptionID
+	sub.CreatedAt = time.Now()
+	d.state.NextSubscriptionID++
+	d.state.Subscriptions = append(d.state.Subscriptions, &sub)
+	d.dirty = true
+	subCopy := sub
+	d.mu.Unlock()
+
+	// подписки меняются редко - сохраняем сразу, чтобы не потерять секрет
+	if err := d.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &subCopy, nil
+}
+
+// Subscriptions возвращает подписки (userID == 0 - все)
+func (d *Dispatcher) Subscriptions(userID int) []*Subscription {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subs := make([]*Subscription, 0)
+	for _, sub := range d.state.Subscriptions {
+		if userID == 0 || sub.UserID == userID {
+			subCopy := *sub
+			subs = append(subs, &subCopy)
+		}
+	}
+
+	return subs
+}
+
+// RemoveSubscription удаляет подписку вместе с её доставками
+func (d *Dispatcher) RemoveSubscription(id int) error {
+
+	d.mu.Lock()
+	idx := d.subscriptionIndex(id)
+	if idx < 0 {
+		d.mu.Unlock()
+		return ErrNotFound
+	}
+	d.state.Subscriptions = append(d.state.Subscriptions[:idx], d.state.Subscriptions[idx+1:]...)
+
+	deliveries := d.state.Deliveries[:0]
+	for _, delivery := range d.state.Deliveries {
+		if delivery.SubscriptionID != id {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	d.state.Deliveries = deliveries
+	d.dirty = true
+	d.mu.Unlock()
+
+	return d.Flush()
+}
+
+// Deliveries возвращает историю доставок подписки (новые первыми)
+func (d *Dispatcher) Deliveries(subscriptionID int) ([]*Delivery, error) {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.subscriptionIndex(subscriptionID) < 0 {
+		return nil, ErrNotFound
+	}
+
+	deliveries := make([]*Delivery, 0)
+	for i := len(d.state.Deliveries) - 1; i >= 0; i-- {
+		if d.state.Deliveries[i].SubscriptionID == subscriptionID {
+			deliveryCopy := *d.state.Deliveries[i]
+			deliveries = append(deliveries, &deliveryCopy)
+		}
+	}
+
+	return deliveries, nil
+}
+
+// subscriptionIndex ищет подписку по ID (вызывается под d.mu)
+func (d *Dispatcher) subscriptionIndex(id int) int {
+
+	for i, sub := range d.state.Subscriptions {
+		if sub.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Notify кладёт изменение в outbox для всех подходящих подписок и сохраняет outbox на диск
+// до возврата, чтобы падение процесса не потеряло доставку (по сигнатуре подходит как storage.Listener)
+func (d *Dispatcher) Notify(change storage.Change) {
+
+	d.mu.Lock()
+
+	now := time.Now()
+	queued := false
+
+	for _, sub := range d.state.Subscriptions {
+		if !sub.matches(change) {
+			continue
+		}
+
+		payload, err := json.Marshal(Payload{
+			DeliveryID: d.state.NextDeliveryID,
+			Type:       string(change.Type),
+			Change:     change,
+		})
+		if err != nil {
+			continue
+		}
+
+		d.state.Deliveries = append(d.state.Deliveries, &Delivery{
+			ID:             d.state.NextDeliveryID,
+			SubscriptionID: sub.ID,
+			EventType:      string(change.Type),
+			Payload:        payload,
+			Status:         StatusPending,
+			NextAttempt:    now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+		d.state.NextDeliveryID++
+		queued = true
+	}
+
+	if !queued {
+		d.mu.Unlock()
+		return
+	}
+	d.dirty = true
+	d.mu.Unlock()
+
+	// ошибку записи вернуть некому: доставка осталась в памяти, запись повторит фоновый сброс
+	_ = d.Flush()
+
+	d.signal()
+}
+
+// signal будит фоновую доставку, если она ещё не разбужена
+func (d *Dispatcher) signal() {
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start запускает фоновую доставку до отмены контекста
+func (d *Dispatcher) Start(ctx context.Context) {
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.cfg.PollInterval)
+		defer ticker.Stop()
+
+		// ошибка записи не останавливает доставку: состояние в памяти, сброс повторится
+		var flushed time.Time
+		for {
+			d.deliverDue(ctx)
+			if time.Since(flushed) >= d.cfg.PollInterval {
+				_ = d.Flush()
+				flushed = time.Now()
+			}
+
+			select {
+			case <-ctx.Done():
+				d.workers.Wait()
+				_ = d.Flush()
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// Wait дожидается остановки фоновой доставки
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// deliverDue запускает отправку доставок, время которых пришло: у каждой подписки своя очередь,
+// подписки обслуживаются параллельно (не больше Workers), так что медленный приёмник не задерживает остальных
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+
+	for _, queue := range d.due() {
+		d.workers.Add(1)
+		go func() {
+			defer d.workers.Done()
+
+			for _, delivery := range queue {
+				if ctx.Err() != nil {
+					break
+				}
+				d.deliver(ctx, delivery)
+			}
+
+			d.mu.Lock()
+			delete(d.busy, queue[0].subscriptionID)
+			d.mu.Unlock()
+
+			// за время отправки могли появиться новые доставки этой подписки
+			d.signal()
+		}()
+	}
+}
+
+// due выбирает ожидающие доставки свободных подписок (по очереди на подписку, в порядке
+// постановки в outbox) и помечает эти подписки занятыми
+func (d *Dispatcher) due() [][]pendingDelivery {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	queues := make([][]pendingDelivery, 0)
+	index := make(map[int]int) // подписка -> её очередь в queues
+
+	for _, delivery := range d.state.Deliveries {
+		if delivery.Status != StatusPending || delivery.NextAttempt.After(now) || d.busy[delivery.SubscriptionID] {
+			continue
+		}
+		idx := d.subscriptionIndex(delivery.SubscriptionID)
+		if idx < 0 {
+			continue
+		}
+		q, ok := index[delivery.SubscriptionID]
+		if !ok {
+			if len(d.busy)+len(queues) >= d.cfg.Workers {
+				continue // все воркеры заняты: подписка дождётся следующего прохода
+			}
+			q = len(queues)
+			index[delivery.SubscriptionID] = q
+			queues = append(queues, nil)
+		}
+		queues[q] = append(queues[q], pendingDelivery{
+			subscriptionID: delivery.SubscriptionID,
+			id:             delivery.ID,
+			url:            d.state.Subscriptions[idx].URL,
+			secret:         d.state.Subscriptions[idx].Secret,
+			event:          delivery.EventType,
+			payload:        delivery.Payload,
+		})
+	}
+	for subscriptionID := range index {
+		d.busy[subscriptionID] = true
+	}
+
+	return queues
+}
+
+// pendingDelivery - снимок данных для отправки без удержания d.mu
+type pendingDelivery struct {
+	subscriptionID int
+	id             int
+	url            string
+	secret         string
+	event          string
+	payload        []byte
+}
+
+// deliver делает одну попытку доставки и записывает её результат
+func (d *Dispatcher) deliver(ctx context.Context, pd pendingDelivery) {
+
+	code, err := d.send(ctx, pd)
+	if err != nil && ctx.Err() != nil {
+		// остановка сервера - не неудачная попытка: доставка уйдёт после перезапуска
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var delivery *Delivery
+	for _, candidate := range d.state.Deliveries {
+		if candidate.ID == pd.id {
+			delivery = candidate
+			break
+		}
+	}
+	if delivery == nil { // подписку удалили, пока шла отправка
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.NextAttempt = time.Time{}
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = StatusFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = time.Time{}
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	d.trimHistory(delivery.SubscriptionID)
+	d.dirty = true
+}
+
+// send отправляет тело доставки приёмнику, возвращает HTTP-статус или ошибку
+func (d *Dispatcher) send(ctx context.Context, pd pendingDelivery) (int, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pd.url, bytes.NewReader(pd.payload))
+	if err != nil {
+		return 0, fmt.Errorf("не удалось сформировать запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, pd.event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(pd.id))
+	req.Header.Set(HeaderSignature, Sign(pd.secret, pd.payload))
+
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отправки: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("приёмник ответил %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff возвращает паузу перед следующей попыткой (экспоненциально растёт)
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+
+	pause := d.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		pause *= 2
+		if pause >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+
+	return pause
+}
+
+// trimHistory удаляет самые старые завершённые доставки сверх лимита (вызывается под d.mu)
+func (d *Dispatcher) trimHistory(subscriptionID int) {
+
+	finished := make([]*Delivery, 0)
+	for _, delivery := range d.state.Deliveries {
+		if delivery.SubscriptionID == subscriptionID && delivery.Status != StatusPending {
+			finished = append(finished, delivery)
+		}
+	}
+	if len(finished) <= d.cfg.HistoryLimit {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i].ID < finished[j].ID })
+	drop := make(map[int]bool)
+	for _, delivery := range finished[:len(finished)-d.cfg.HistoryLimit] {
+		drop[delivery.ID] = true
+	}
+
+	deliveries := d.state.Deliveries[:0]
+	for _, delivery := range d.state.Deliveries {
+		if !drop[delivery.ID] {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	d.state.Deliveries = deliveries
+}
@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/storage"
+)
+
+// заголовки, которые получает приёмник вебхука
+const (
+	HeaderSignature = "X-Calendar-Signature" // HMAC-SHA256 тела запроса в виде sha256=<hex>
+	HeaderEvent     = "X-Calendar-Event"     // тип изменения
+	HeaderDelivery  = "X-Calendar-Delivery"  // ID доставки (одинаков для всех попыток)
+)
+
+// статусы доставки
+const (
+	StatusPending   = "pending"   // ожидает отправки (лежит в outbox)
+	StatusDelivered = "delivered" // приёмник ответил 2xx
+	StatusFailed    = "failed"    // попытки исчерпаны
+)
+
+// Subscription описывает подписку на изменения календаря
+type Subscription struct {
+	ID         int                  `json:"id"`                    // id подписки
+	URL        string               `json:"url"`                   // куда отправлять
+	Secret     string               `json:"-"`                     // ключ для подписи HMAC (наружу только в CreatedSubscription)
+	EventTypes []storage.ChangeType `json:"event_types,omitempty"` // типы изменений (пусто - все)
+	UserID     int                  `json:"user_id,omitempty"`     // календарь пользователя (0 - все)
+	CreatedAt  time.Time            `json:"created_at"`            // время создания
+}
+
+// CreatedSubscription - ответ на создание подписки: единственное место, где клиент видит секрет
+type CreatedSubscription struct {
+	Subscription
+	Secret string `json:"secret"`
+}
+
+// Delivery описывает доставку одного изменения одной подписке
+type Delivery struct {
+	ID             int             `json:"id"`                      // id доставки
+	SubscriptionID int             `json:"subscription_id"`         // id подписки
+	EventType      string          `json:"event_type"`              // тип изменения
+	Payload        json.RawMessage `json:"payload"`                 // отправляемое тело
+	Status         string          `json:"status"`                  // pending / delivered / failed
+	Attempts       int             `json:"attempts"`                // количество сделанных попыток
+	NextAttempt    time.Time       `json:"next_attempt,omitempty"`  // время следующей попытки
+	ResponseCode   int             `json:"response_code,omitempty"` // последний HTTP-статус приёмника
+	LastError      string          `json:"last_error,omitempty"`    // последняя ошибка
+	CreatedAt      time.Time       `json:"created_at"`              // время постановки в outbox
+	UpdatedAt      time.Time       `json:"updated_at"`              // время последнего изменения статуса
+}
+
+// Payload - тело запроса, которое получает приёмник
+type Payload struct {
+	DeliveryID int            `json:"delivery_id"` // id доставки (для дедупликации на стороне приёмника)
+	Type       string         `json:"type"`        // тип изменения
+	Change     storage.Change `json:"change"`      // само изменение
+}
+
+// Sign возвращает значение заголовка подписи для тела запроса
+func Sign(secret string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись тела запроса (пригодится приёмникам на Go)
+func Verify(secret string, body []byte, signature string) bool {
+
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// matches проверяет, интересно ли изменение подписке
+func (sub *Subscription) matches(change storage.Change) bool {
+
+	if sub.UserID != 0 && sub.UserID != change.UserID {
+		return false
+	}
+	if len(sub.EventTypes) == 0 {
+		return true
+	}
+
+	return slices.Contains(sub.EventTypes, change.Type)
+}
+
+// validate проверяет параметры новой подписки
+func (sub *Subscription) validate() error {
+
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url должен быть абсолютным http(s) адресом")
+	}
+	if sub.UserID < 0 {
+		return fmt.Errorf("user_id не может быть отрицательным")
+	}
+	for _, eventType := range sub.EventTypes {
+		if !slices.Contains(storage.ChangeTypes(), eventType) {
+			return fmt.Errorf("неизвестный тип события %q", eventType)
+		}
+	}
+
+	return nil
+}
+
+// newSecret генерирует секрет подписки, если клиент его не передал
+func newSecret() (string, error) {
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать секрет: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -2,10 +2,12 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -126,7 +128,8 @@ func TestCalendarAPI_Integration(t *testing.T) {
             "user_id": 123,
             "date": "2026-01-15",
             "title": "Новое название",
-            "content": "Новое описание"
+            "content": "Новое описание",
+            "version": 1
         }`
 
 		resp, err := client.Post(server.URL+"/update_event", "application/json", bytes.NewBufferString(body))
@@ -177,7 +180,8 @@ func TestCalendarAPI_Integration(t *testing.T) {
 	t.Run("DELETE event", func(t *testing.T) {
 		body := `{
             "user_id": 123,
-            "event_id": 1
+            "event_id": 1,
+            "version": 2
         }`
 
 		resp, err := client.Post(server.URL+"/delete_event", "application/json", bytes.NewBufferString(body))
@@ -228,7 +232,7 @@ func TestCalendarAPI_Integration(t *testing.T) {
 	})
 
 	t.Run("NEGATIVE: update non-existent event", func(t *testing.T) {
-		body := `{"id":999,"user_id":123,"date":"2026-01-15","title":"Test"}`
+		body := `{"id":999,"user_id":123,"date":"2026-01-15","title":"Test","version":1}`
 		resp, err := client.Post(server.URL+"/update_event", "application/json", bytes.NewBufferString(body))
 		require.NoError(t, err)
 		defer resp.Body.Close()
@@ -263,3 +267,676 @@ func TestWriterJSONError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	assert.Contains(t, rec.Body.String(), "fatal error")
 }
+
+// TestOptimisticConcurrency проверяет версии событий, ETag и отказ при конфликте
+func TestOptimisticConcurrency(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /event", apiMock.GetEventHandler)
+	mux.HandleFunc("POST /update_event", apiMock.UpdateEventHandler)
+	mux.HandleFunc("POST /delete_event", apiMock.DeleteEventHandler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	id, err := mock.Create(1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "")
+	require.NoError(t, err)
+
+	// чтение отдаёт версию в ETag
+	resp, err := client.Get(fmt.Sprintf("%s/event?user_id=1&event_id=%d", server.URL, id))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	update := func(ifMatch string) *http.Response {
+		body := fmt.Sprintf(`{"id":%d,"user_id":1,"date":"2026-01-15","title":"Изменено"}`, id)
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/update_event", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// без версии - 428
+	assert.Equal(t, http.StatusPreconditionRequired, update("").StatusCode)
+
+	// первый клиент успешно обновляет, получает новую версию
+	resp = update(etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	// второй клиент со старой версией - 412, данные не перезаписаны
+	assert.Equal(t, http.StatusPreconditionFailed, update(etag).StatusCode)
+	event, err := mock.Get(1, id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, event.Version)
+
+	// удаление со старой версией тоже отклоняется
+	body := fmt.Sprintf(`{"user_id":1,"event_id":%d,"version":1}`, id)
+	resp, err = client.Post(server.URL+"/delete_event", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+// TestPatchEvent проверяет частичное обновление события через merge patch и JSON Patch
+func TestPatchEvent(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /event", apiMock.PatchEventHandler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	id, err := mock.Create(1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "Описание")
+	require.NoError(t, err)
+	url := fmt.Sprintf("%s/event?user_id=1&event_id=%d", server.URL, id)
+
+	patch := func(contentType, ifMatch, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// меняем только дату - остальное сохраняется
+	t.Run("merge patch: omitted fields are kept", func(t *testing.T) {
+		resp := patch("application/merge-patch+json", `"1"`, `{"date":"2026-01-16"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+		event, err := mock.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, 16, event.Date.Day())
+		assert.Equal(t, "Встреча", event.Title)
+		assert.Equal(t, "Описание", event.Content, "content не передавали - он не должен стереться")
+	})
+
+	// null очищает поле
+	t.Run("merge patch: null clears field", func(t *testing.T) {
+		resp := patch("application/merge-patch+json", `"2"`, `{"content":null}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		event, err := mock.Get(1, id)
+		require.NoError(t, err)
+		assert.Empty(t, event.Content)
+	})
+
+	// валидация применяется к результату
+	t.Run("merge patch: cleared title is rejected", func(t *testing.T) {
+		resp := patch("application/merge-patch+json", `"3"`, `{"title":null}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("merge patch: read-only field", func(t *testing.T) {
+		resp := patch("application/merge-patch+json", `"3"`, `{"user_id":2}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("json patch: test and replace", func(t *testing.T) {
+		body := `[
+			{"op":"test","path":"/title","value":"Встреча"},
+			{"op":"replace","path":"/title","value":"Планёрка"},
+			{"op":"add","path":"/content","value":"Новое"}
+		]`
+		resp := patch("application/json-patch+json", `"3"`, body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		event, err := mock.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Планёрка", event.Title)
+		assert.Equal(t, "Новое", event.Content)
+	})
+
+	t.Run("json patch: failed test", func(t *testing.T) {
+		body := `[{"op":"test","path":"/title","value":"Встреча"},{"op":"remove","path":"/content"}]`
+		resp := patch("application/json-patch+json", `"4"`, body)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("NEGATIVE: stale version, missing version, unknown format", func(t *testing.T) {
+		assert.Equal(t, http.StatusPreconditionFailed, patch("application/merge-patch+json", `"1"`, `{"title":"X"}`).StatusCode)
+		assert.Equal(t, http.StatusPreconditionRequired, patch("application/merge-patch+json", "", `{"title":"X"}`).StatusCode)
+		assert.Equal(t, http.StatusUnsupportedMediaType, patch("text/plain", `"4"`, `{"title":"X"}`).StatusCode)
+	})
+
+	// дата в патче - день, время хранимого события не теряется
+	t.Run("merge patch: time of day is kept", func(t *testing.T) {
+		moscow := time.FixedZone("MSK", 3*60*60)
+		timedID, err := mock.Create(1, time.Date(2026, 1, 15, 10, 30, 0, 0, moscow), "Созвон", "")
+		require.NoError(t, err)
+		timedURL := fmt.Sprintf("%s/event?user_id=1&event_id=%d", server.URL, timedID)
+
+		send := func(ifMatch, body string) int {
+			req, err := http.NewRequest(http.MethodPatch, timedURL, bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.Header.Set("If-Match", ifMatch)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		require.Equal(t, http.StatusOK, send(`"1"`, `{"title":"Созвон с командой"}`))
+		event, err := mock.Get(1, timedID)
+		require.NoError(t, err)
+		assert.True(t, time.Date(2026, 1, 15, 10, 30, 0, 0, moscow).Equal(event.Date), "Дату не меняли - она остаётся прежней")
+
+		require.Equal(t, http.StatusOK, send(`"2"`, `{"date":"2026-01-20"}`))
+		event, err = mock.Get(1, timedID)
+		require.NoError(t, err)
+		assert.True(t, time.Date(2026, 1, 20, 10, 30, 0, 0, moscow).Equal(event.Date), "Перенос на другой день сохраняет время")
+	})
+}
+
+// interleavingStorage выполняет hook один раз - сразу после того, как GetContext прочитал событие,
+// а afterUpdate - сразу после успешного UpdateByContext
+type interleavingStorage struct {
+	*storage.Storage
+	hook        func()
+	afterUpdate func()
+}
+
+func (s *interleavingStorage) UpdateByContext(ctx context.Context, actorID int, event *storage.Event) error {
+
+	err := s.Storage.UpdateByContext(ctx, actorID, event)
+	if hook := s.afterUpdate; hook != nil && err == nil {
+		s.afterUpdate = nil
+		hook()
+	}
+
+	return err
+}
+
+func (s *interleavingStorage) GetContext(ctx context.Context, userID, eventID int) (*storage.Event, error) {
+
+	event, err := s.Storage.GetContext(ctx, userID, eventID)
+	if hook := s.hook; hook != nil {
+		s.hook = nil
+		hook()
+	}
+
+	return event, err
+}
+
+// TestPatchEventInterleaved проверяет, что патч с If-Match: * не затирает изменение,
+// сделанное другим патчем между чтением события и записью
+func TestPatchEventInterleaved(t *testing.T) {
+
+	db := &interleavingStorage{Storage: newMockStorage()}
+	handler := api.NewHandler(db)
+
+	id, err := db.Create(1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "")
+	require.NoError(t, err)
+	url := fmt.Sprintf("/event?user_id=1&event_id=%d", id)
+
+	patch := func(body string) int {
+		req := httptest.NewRequest(http.MethodPatch, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", "*")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// второй патч выполняется, пока первый уже прочитал событие, но ещё не записал
+	var second int
+	db.hook = func() { second = patch(`{"content":"Повестка"}`) }
+	first := patch(`{"title":"Планёрка"}`)
+
+	assert.Equal(t, http.StatusOK, second)
+	assert.Equal(t, http.StatusPreconditionFailed, first, "Патч по устаревшему состоянию должен получить конфликт версий")
+
+	event, err := db.Get(1, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Повестка", event.Content, "Изменение второго патча не должно потеряться")
+	assert.Equal(t, "Встреча", event.Title)
+	assert.Equal(t, 2, event.Version)
+}
+
+// TestUpdateResponseInterleaved проверяет, что ответ на обновление описывает записанную им версию,
+// даже если сразу после записи событие успели изменить ещё раз
+func TestUpdateResponseInterleaved(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		request func(id int) *http.Request
+		title   func(body []byte) string
+	}{
+		{
+			name: "v1 update_event",
+			request: func(id int) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/update_event", bytes.NewBufferString(
+					fmt.Sprintf(`{"id":%d,"user_id":1,"date":"2026-01-15","title":"Планёрка","version":1}`, id)))
+				req.Header.Set("Prefer", "return=representation")
+				return req
+			},
+			title: func(body []byte) string {
+				var answer struct {
+					Result storage.Event `json:"result"`
+				}
+				require.NoError(t, json.Unmarshal(body, &answer))
+				return answer.Result.Title
+			},
+		},
+		{
+			name: "v2 PUT",
+			request: func(id int) *http.Request {
+				req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/v2/users/1/events/%d", id),
+					bytes.NewBufferString(`{"date":"2026-01-15","title":"Планёрка"}`))
+				req.Header.Set("If-Match", `"1"`)
+				return req
+			},
+			title: func(body []byte) string {
+				var event storage.Event
+				require.NoError(t, json.Unmarshal(body, &event))
+				return event.Title
+			},
+		},
+		{
+			name: "PATCH",
+			request: func(id int) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/v2/users/1/events/%d", id),
+					bytes.NewBufferString(`{"title":"Планёрка"}`))
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+				req.Header.Set("If-Match", `"1"`)
+				return req
+			},
+			title: func(body []byte) string {
+				var event storage.Event
+				require.NoError(t, json.Unmarshal(body, &event))
+				return event.Title
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &interleavingStorage{Storage: newMockStorage()}
+			handler := api.NewHandler(db)
+
+			date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+			id, err := db.Create(1, date, "Встреча", "")
+			require.NoError(t, err)
+
+			// чужая правка успевает сразу после записи, до того как хэндлер собрал ответ
+			db.afterUpdate = func() {
+				require.NoError(t, db.Storage.Update(&storage.Event{ID: id, UserID: 1, Date: date, Title: "Чужая правка"}))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.request(id))
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"), "ETag должен описывать версию, записанную этим запросом")
+			assert.Equal(t, "Планёрка", tt.title(rec.Body.Bytes()))
+
+			event, err := db.Get(1, id)
+			require.NoError(t, err)
+			assert.Equal(t, 3, event.Version)
+		})
+	}
+}
+
+// TestAPIv2 проверяет ресурсный API /v2: коды ответов, Location, ETag и тела-ресурсы
+func TestAPIv2(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v2/users/{id}/events", apiMock.V2ListEventsHandler)
+	mux.HandleFunc("POST /v2/users/{id}/events", apiMock.V2CreateEventHandler)
+	mux.HandleFunc("GET /v2/users/{id}/events/{eventID}", apiMock.V2GetEventHandler)
+	mux.HandleFunc("PUT /v2/users/{id}/events/{eventID}", apiMock.V2ReplaceEventHandler)
+	mux.HandleFunc("PATCH /v2/users/{id}/events/{eventID}", apiMock.V2PatchEventHandler)
+	mux.HandleFunc("DELETE /v2/users/{id}/events/{eventID}", apiMock.V2DeleteEventHandler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	do := func(method, path, ifMatch, contentType, body string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var data bytes.Buffer
+		_, err = data.ReadFrom(resp.Body)
+		require.NoError(t, err)
+		return resp, data.Bytes()
+	}
+
+	// создание возвращает сам ресурс и его адрес
+	resp, data := do(http.MethodPost, "/v2/users/7/events", "", "application/json", `{"date":"2026-01-15","title":"Встреча","content":"Описание"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created storage.Event
+	require.NoError(t, json.Unmarshal(data, &created))
+	assert.Equal(t, 7, created.UserID)
+	assert.Equal(t, "Встреча", created.Title)
+	location := resp.Header.Get("Location")
+	assert.Equal(t, fmt.Sprintf("/v2/users/7/events/%d", created.ID), location)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	// чтение по Location
+	resp, data = do(http.MethodGet, location, "", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var fetched storage.Event
+	require.NoError(t, json.Unmarshal(data, &fetched))
+	assert.Equal(t, created.ID, fetched.ID)
+
+	// список за день
+	resp, data = do(http.MethodGet, "/v2/users/7/events?date=2026-01-15&period=day", "", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var events []*storage.Event
+	require.NoError(t, json.Unmarshal(data, &events))
+	assert.Len(t, events, 1)
+
+	// у пользователя без событий - пустой список, а не ошибка
+	resp, data = do(http.MethodGet, "/v2/users/99/events?date=2026-01-15", "", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `[]`, string(data))
+
+	// PUT заменяет событие целиком
+	resp, data = do(http.MethodPut, location, `"1"`, "application/json", `{"date":"2026-01-20","title":"Перенесли"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var replaced storage.Event
+	require.NoError(t, json.Unmarshal(data, &replaced))
+	assert.Equal(t, "Перенесли", replaced.Title)
+	assert.Empty(t, replaced.Content, "PUT без content очищает его")
+	assert.Equal(t, 2, replaced.Version)
+
+	// PATCH
+	resp, _ = do(http.MethodPatch, location, `"2"`, "application/merge-patch+json", `{"content":"Вернули описание"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+
+	// конфликт версий и отсутствие версии
+	resp, _ = do(http.MethodPut, location, `"1"`, "application/json", `{"date":"2026-01-20","title":"X"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do(http.MethodDelete, location, "", "", "")
+	assert.Equal(t, http.StatusPreconditionRequired, resp.StatusCode)
+
+	// удаление и последующее 404
+	resp, _ = do(http.MethodDelete, location, `"3"`, "", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, data = do(http.MethodGet, location, "", "", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, string(data), "error")
+}
+
+// TestStructuredResponses проверяет, что по запросу create/update возвращают событие целиком
+func TestStructuredResponses(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /create_event", apiMock.CreateEventHandler)
+	mux.HandleFunc("POST /update_event", apiMock.UpdateEventHandler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	// ответ с событием в result
+	type eventAnswer struct {
+		Result storage.Event `json:"result"`
+		Error  string        `json:"error"`
+	}
+
+	// без опции - прежняя строка
+	body := `{"user_id":1,"date":"2026-01-15","title":"Встреча"}`
+	resp, err := client.Post(server.URL+"/create_event", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	var legacy api.Answer
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&legacy))
+	resp.Body.Close()
+	assert.Equal(t, "событие создано, ID: 1", legacy.Result)
+
+	// Prefer: return=representation - событие целиком
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/create_event", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Prefer", "return=representation")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	var created eventAnswer
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "return=representation", resp.Header.Get("Preference-Applied"))
+	assert.Equal(t, 2, created.Result.ID)
+	assert.Equal(t, 1, created.Result.Version)
+	assert.False(t, created.Result.CreatedAt.IsZero(), "created_at заполняет сервер")
+	assert.True(t, created.Result.Date.Equal(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)))
+
+	// ?return=event для обновления
+	body = `{"id":2,"user_id":1,"date":"2026-01-16","title":"Перенесли","version":1}`
+	resp, err = client.Post(server.URL+"/update_event?return=event", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	var updated eventAnswer
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&updated))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Перенесли", updated.Result.Title)
+	assert.Equal(t, 2, updated.Result.Version)
+	assert.False(t, updated.Result.UpdatedAt.Before(updated.Result.CreatedAt))
+}
+
+func TestBatchEvents(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /batch_events", apiMock.BatchEventsHandler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	type batchAnswer struct {
+		Result api.BatchResponse `json:"result"`
+		Error  string            `json:"error"`
+	}
+
+	send := func(body string) (int, batchAnswer) {
+		resp, err := client.Post(server.URL+"/batch_events", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var answer batchAnswer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&answer))
+		return resp.StatusCode, answer
+	}
+
+	// без atomic ошибка одной операции не мешает остальным
+	status, answer := send(`{"operations":[
+		{"op":"create","user_id":1,"date":"2026-01-15","title":"Первое"},
+		{"op":"create","user_id":1,"date":"плохая дата","title":"Второе"},
+		{"op":"create","user_id":1,"date":"2026-01-16","title":"Третье"}
+	]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, answer.Result.Applied)
+	require.Len(t, answer.Result.Results, 3)
+	assert.Equal(t, http.StatusCreated, answer.Result.Results[0].Status)
+	assert.Equal(t, http.StatusBadRequest, answer.Result.Results[1].Status)
+	assert.NotEmpty(t, answer.Result.Results[1].Error)
+	assert.Equal(t, http.StatusCreated, answer.Result.Results[2].Status)
+	assert.Len(t, mock.Events[1], 2)
+
+	// atomic: конфликт версии отменяет весь пакет
+	status, answer = send(`{"atomic":true,"operations":[
+		{"op":"create","user_id":1,"date":"2026-01-17","title":"Четвёртое"},
+		{"op":"update","id":1,"user_id":1,"date":"2026-01-15","title":"Изменено","version":5}
+	]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	assert.False(t, answer.Result.Applied)
+	assert.NotEmpty(t, answer.Error)
+	assert.Equal(t, http.StatusFailedDependency, answer.Result.Results[0].Status, "Успешная операция тоже отменена")
+	assert.Equal(t, http.StatusPreconditionFailed, answer.Result.Results[1].Status)
+	assert.Len(t, mock.Events[1], 2, "Пакет не должен примениться частично")
+
+	// atomic: всё успешно
+	status, answer = send(`{"atomic":true,"operations":[
+		{"op":"update","id":1,"user_id":1,"date":"2026-01-15","title":"Изменено","version":1},
+		{"op":"delete","user_id":1,"event_id":2,"version":1}
+	]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, answer.Result.Applied)
+	require.NotNil(t, answer.Result.Results[0].Event)
+	assert.Equal(t, 2, answer.Result.Results[0].Event.Version)
+	require.Len(t, mock.Events[1], 1)
+	assert.Equal(t, "Изменено", mock.Events[1][0].Title)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /create_event", apiMock.Idempotent(apiMock.CreateEventHandler))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	send := func(key, body string) (*http.Response, api.Answer) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/create_event", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(api.HeaderIdempotencyKey, key)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var answer api.Answer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&answer))
+		return resp, answer
+	}
+
+	body := `{"user_id":1,"date":"2026-01-15","title":"Встреча"}`
+
+	// первый запрос выполняется, повтор получает тот же ответ
+	resp, first := send("key-1", body)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(api.HeaderIdempotentReplayed))
+
+	resp, replay := send("key-1", body)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(api.HeaderIdempotentReplayed))
+	assert.Equal(t, first.Result, replay.Result)
+	assert.Len(t, mock.Events[1], 1, "Повтор не должен создавать дубль")
+
+	// тот же ключ с другим телом - ошибка
+	resp, answer := send("key-1", `{"user_id":1,"date":"2026-01-16","title":"Другая"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.NotEmpty(t, answer.Error)
+
+	// ключи разных пользователей не пересекаются
+	resp, _ = send("key-1", `{"user_id":2,"date":"2026-01-15","title":"Встреча"}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// без ключа каждый запрос выполняется
+	send("", body)
+	send("", body)
+	assert.Len(t, mock.Events[1], 3)
+
+	// одновременные повторы одного ключа: событие создаётся один раз, остальные получают 201 или 409
+	concurrent := `{"user_id":3,"date":"2026-01-15","title":"Встреча"}`
+	var wg sync.WaitGroup
+	statuses := make([]int, 8)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/create_event", bytes.NewBufferString(concurrent))
+			if err != nil {
+				return
+			}
+			req.Header.Set(api.HeaderIdempotencyKey, "key-concurrent")
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	for _, status := range statuses {
+		assert.Contains(t, []int{http.StatusCreated, http.StatusConflict}, status)
+	}
+	assert.Len(t, mock.Events[3], 1, "Одновременные повторы не должны создавать дубли")
+
+	// тот же ключ и тело, но другие параметры запроса - это другой запрос
+	req := httptest.NewRequest(http.MethodPost, "/create_event?notify=false", bytes.NewBufferString(body))
+	req.Header.Set(api.HeaderIdempotencyKey, "key-1")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+// TestIdempotencyKeyLimit проверяет, что ключей одного пользователя хранится не больше лимита:
+// самые старые вытесняются, свежие по-прежнему отдают сохранённый ответ
+func TestIdempotencyKeyLimit(t *testing.T) {
+
+	mock := newMockStorage()
+	apiMock := api.NewAPI(mock)
+	handler := apiMock.Idempotent(apiMock.CreateEventHandler)
+
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/create_event",
+			bytes.NewBufferString(`{"user_id":1,"date":"2026-01-15","title":"Встреча"}`))
+		req.Header.Set(api.HeaderIdempotencyKey, key)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	const limit = 1000
+	for i := range limit + 1 {
+		require.Equal(t, http.StatusCreated, send(fmt.Sprintf("key-%d", i)).Code)
+	}
+	require.Len(t, mock.Events[1], limit+1)
+
+	// самый старый ключ вытеснен: запрос выполняется заново
+	rec := send("key-0")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(api.HeaderIdempotentReplayed))
+	assert.Len(t, mock.Events[1], limit+2)
+
+	// последний ключ на месте
+	rec = send(fmt.Sprintf("key-%d", limit))
+	assert.Equal(t, "true", rec.Header().Get(api.HeaderIdempotentReplayed))
+	assert.Len(t, mock.Events[1], limit+2)
+}
@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// calctl - собранная утилита и сервер, к которому она обращается
+type calctl struct {
+	bin    string
+	server string
+	client *client.Client
+}
+
+// buildCalctl собирает cmd/calctl во временный каталог теста
+// (утилита - пакет main, поэтому проверяем её как пользователь - запуском бинарника)
+func buildCalctl(t *testing.T) string {
+
+	bin := filepath.Join(t.TempDir(), "calctl")
+	build := exec.Command("go", "build", "-o", bin, "github.com/IPampurin/calendar-server/cmd/calctl")
+	output, err := build.CombinedOutput()
+	require.NoError(t, err, "сборка calctl: %s", output)
+
+	return bin
+}
+
+// newCalctl поднимает для собранной утилиты отдельный сервер API
+func newCalctl(t *testing.T, bin string) *calctl {
+
+	server, _ := newAPIServer(t, nil)
+	c, err := client.New(server.URL, client.WithHTTPClient(server.Client()), client.WithRetries(0, 0))
+	require.NoError(t, err)
+
+	return &calctl{bin: bin, server: server.URL, client: c}
+}
+
+// run запускает команду и возвращает её вывод (stderr - в тексте ошибки)
+func (c *calctl) run(args ...string) (string, error) {
+
+	cmd := exec.Command(c.bin, append([]string{"-server", c.server}, args...)...)
+	cmd.Env = append(os.Environ(), "CALCTL_TOKEN=")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), &calctlError{err: err, stderr: stderr.String()}
+	}
+
+	return stdout.String(), nil
+}
+
+// calctlError - неудачный запуск calctl с его stderr
+type calctlError struct {
+	err    error
+	stderr string
+}
+
+func (e *calctlError) Error() string {
+	return e.err.Error() + ": " + e.stderr
+}
+
+// titles возвращает заголовки событий по порядку
+func titles(events []*client.Event) []string {
+
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.Title)
+	}
+
+	return result
+}
+
+// date возвращает день в UTC
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCalctlMonth(t *testing.T) {
+
+	cli := newCalctl(t, buildCalctl(t))
+	ctx := context.Background()
+	_, err := cli.client.Create(ctx, 1, date(2024, time.February, 29), "Отчёт", "")
+	require.NoError(t, err)
+	_, err = cli.client.Create(ctx, 1, date(2024, time.February, 1), "Встреча\nвторая строка", "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		date string
+		want []string
+	}{
+		{
+			name: "месяц с событиями, начинается с четверга",
+			date: "2024-02-10",
+			want: []string{
+				"           Февраль 2024",
+				" Пн   Вт   Ср   Чт   Пт   Сб   Вс",
+				"                 1 *  2    3    4",
+				"  5    6    7    8    9   10   11",
+				" 12   13   14   15   16   17   18",
+				" 19   20   21   22   23   24   25",
+				" 26   27   28   29 *",
+				"",
+				" 1  #2 Встреча вторая строка",
+				"29  #1 Отчёт",
+			},
+		},
+		{
+			name: "пустой месяц, начинается с понедельника",
+			date: "2025-09-30",
+			want: []string{
+				"           Сентябрь 2025",
+				" Пн   Вт   Ср   Чт   Пт   Сб   Вс",
+				"  1    2    3    4    5    6    7",
+				"  8    9   10   11   12   13   14",
+				" 15   16   17   18   19   20   21",
+				" 22   23   24   25   26   27   28",
+				" 29   30",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := cli.run("month", "-user", "1", "-date", tt.date)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, strings.Split(strings.TrimRight(out, "\n"), "\n"))
+		})
+	}
+}
+
+func TestCalctlListRange(t *testing.T) {
+
+	cli := newCalctl(t, buildCalctl(t))
+	ctx := context.Background()
+	for _, event := range []struct {
+		date  time.Time
+		title string
+	}{
+		{date(2026, time.March, 5), "Март"},
+		{date(2026, time.January, 19), "До диапазона"},
+		{date(2026, time.January, 20), "Начало"},
+		{date(2026, time.February, 14), "Февраль"},
+		{date(2026, time.March, 6), "После диапазона"},
+		{date(2026, time.February, 14), "Февраль второе"},
+	} {
+		_, err := cli.client.Create(ctx, 1, event.date, event.title, "")
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    []string
+		wantErr bool
+	}{
+		{"несколько месяцев, границы включительно", "2026-01-20", "2026-03-05", []string{"Начало", "Февраль", "Февраль второе", "Март"}, false},
+		{"один день", "2026-02-14", "2026-02-14", []string{"Февраль", "Февраль второе"}, false},
+		{"пустой диапазон", "2026-04-01", "2026-06-30", []string{}, false},
+		{"конец раньше начала", "2026-03-01", "2026-02-01", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := cli.run("list", "-user", "1", "-period", "range", "-from", tt.from, "-to", tt.to, "-o", "json")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var events []*client.Event
+			require.NoError(t, json.Unmarshal([]byte(out), &events))
+			assert.Equal(t, tt.want, titles(events))
+		})
+	}
+}
+
+func TestCalctlImport(t *testing.T) {
+
+	bin := buildCalctl(t)
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+		return path
+	}
+
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20260315\r\nSUMMARY:Из календаря\r\n" +
+		"DESCRIPTION:Описание\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	tests := []struct {
+		name    string
+		file    string
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "json с датой и RFC 3339",
+			file: write("events.json", `[{"date":"2026-03-15","title":"Первое","content":"Описание"},{"date":"2026-03-15T00:00:00Z","title":"Второе"}]`),
+			want: []string{"Первое", "Второе"},
+		},
+		{name: "ics по расширению", file: write("events.ics", ics), want: []string{"Из календаря"}},
+		{name: "ics по содержимому", file: write("events.txt", ics), want: []string{"Из календаря"}},
+		{name: "явный формат", file: write("export", ics), format: "ics", want: []string{"Из календаря"}},
+		{name: "неверная дата", file: write("bad.json", `[{"date":"15.03.2026","title":"Плохое"}]`), wantErr: true},
+		{name: "не массив", file: write("object.json", `{"title":"Одно"}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newCalctl(t, bin)
+
+			args := []string{"import", "-user", "1", "-file", tt.file}
+			if tt.format != "" {
+				args = append(args, "-format", tt.format)
+			}
+			out, err := cli.run(args...)
+			if tt.wantErr {
+				require.Error(t, err)
+				events, err := cli.client.EventsForMonth(context.Background(), 1, date(2026, time.March, 1))
+				require.NoError(t, err)
+				assert.Empty(t, events, "При ошибке разбора ничего не создаётся")
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, out, "импортировано событий: "+strconv.Itoa(len(tt.want)))
+
+			events, err := cli.client.EventsForMonth(context.Background(), 1, date(2026, time.March, 1))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, titles(events))
+			for _, event := range events {
+				assert.True(t, event.Date.Equal(date(2026, time.March, 15)), "Дата события %s", event.Title)
+			}
+		})
+	}
+}
+
+func TestCalctlEdit(t *testing.T) {
+
+	bin := buildCalctl(t)
+	tests := []struct {
+		name    string
+		args    []string
+		want    client.Event
+		wantErr bool
+	}{
+		{
+			name: "только заголовок",
+			args: []string{"-title", "Новое"},
+			want: client.Event{Date: date(2026, time.March, 15), Title: "Новое", Content: "Описание"},
+		},
+		{
+			name: "пустое описание очищает",
+			args: []string{"-content", ""},
+			want: client.Event{Date: date(2026, time.March, 15), Title: "Встреча", Content: ""},
+		},
+		{
+			name: "перенос на другую дату",
+			args: []string{"-date", "2026-04-01"},
+			want: client.Event{Date: date(2026, time.April, 1), Title: "Встреча", Content: "Описание"},
+		},
+		{name: "без изменений", args: nil, wantErr: true},
+		{name: "неверная дата", args: []string{"-date", "01.04.2026"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newCalctl(t, bin)
+			ctx := context.Background()
+			created, err := cli.client.Create(ctx, 1, date(2026, time.March, 15), "Встреча", "Описание")
+			require.NoError(t, err)
+
+			args := append([]string{"edit", "-user", "1", "-id", strconv.Itoa(created.ID)}, tt.args...)
+			_, err = cli.run(args...)
+
+			event, getErr := cli.client.Get(ctx, 1, created.ID)
+			require.NoError(t, getErr)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, created.Version, event.Version, "Событие не должно меняться")
+				return
+			}
+			require.NoError(t, err)
+
+			assert.True(t, event.Date.Equal(tt.want.Date), "Дата: %v", event.Date)
+			assert.Equal(t, tt.want.Title, event.Title)
+			assert.Equal(t, tt.want.Content, event.Content)
+			assert.Equal(t, created.Version+1, event.Version)
+		})
+	}
+}
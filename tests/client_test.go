@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/IPampurin/calendar-server/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAPIServer поднимает httptest-сервер со всеми маршрутами API
+func newAPIServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, *api.API) {
+
+	apiMock := api.NewAPI(newMockStorage())
+
+	mux := http.NewServeMux()
+	for _, route := range apiMock.Routes() {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+
+	var handler http.Handler = mux
+	if wrap != nil {
+		handler = wrap(mux)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server, apiMock
+}
+
+func TestClient(t *testing.T) {
+
+	server, _ := newAPIServer(t, nil)
+	c, err := client.New(server.URL, client.WithHTTPClient(server.Client()), client.WithRetries(0, 0))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	created, err := c.Create(ctx, 1, date, "Встреча", "Описание")
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, 1, created.Version)
+	assert.Equal(t, "Встреча", created.Title)
+
+	// обновление с актуальной версией
+	created.Title = "Перенесли"
+	updated, err := c.Update(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	// устаревшая версия - типизированная ошибка
+	_, err = c.Update(ctx, created)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, client.ErrVersionConflict)
+	var apiErr *client.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.StatusCode)
+	assert.NotEmpty(t, apiErr.Message)
+
+	// выборки по периоду
+	_, err = c.Create(ctx, 1, date.AddDate(0, 0, 10), "Позже", "")
+	require.NoError(t, err)
+
+	day, err := c.EventsForDay(ctx, 1, date)
+	require.NoError(t, err)
+	require.Len(t, day, 1)
+	assert.Equal(t, "Перенесли", day[0].Title)
+
+	week, err := c.EventsForWeek(ctx, 1, date)
+	require.NoError(t, err)
+	assert.Len(t, week, 1)
+
+	month, err := c.EventsForMonth(ctx, 1, date)
+	require.NoError(t, err)
+	assert.Len(t, month, 2)
+
+	// удаление
+	_, err = c.Get(ctx, 1, 1)
+	require.NoError(t, err)
+	require.NoError(t, c.Delete(ctx, 1, 1, updated.Version))
+	_, err = c.Get(ctx, 1, 1)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	// без версии сервер требует её указать
+	err = c.Delete(ctx, 1, 2, 0)
+	assert.ErrorIs(t, err, client.ErrVersionRequired)
+
+	// неверный адрес
+	_, err = client.New("localhost:8081")
+	assert.Error(t, err)
+}
+
+func TestClientRetries(t *testing.T) {
+
+	// первые два запроса "теряются" после обработки: сервер всё сделал, а клиент получил 503
+	var calls, authorized atomic.Int32
+	server, apiMock := newAPIServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "Bearer secret" {
+				authorized.Add(1)
+			}
+			if calls.Add(1) <= 2 {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	c, err := client.New(server.URL,
+		client.WithHTTPClient(server.Client()),
+		client.WithToken("secret"),
+		client.WithRetries(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	event, err := c.Create(context.Background(), 1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.ID)
+	assert.Equal(t, int32(3), calls.Load(), "Два повтора после 503")
+	assert.Equal(t, int32(3), authorized.Load(), "Авторизация в каждой попытке")
+
+	// повторы шли с тем же Idempotency-Key - дублей нет
+	events, err := apiMock.Storage.GetForDay(1, event.Date)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// при исчерпании повторов - ошибка недоступности
+	calls.Store(0)
+	c, err = client.New(server.URL, client.WithHTTPClient(server.Client()), client.WithRetries(1, time.Millisecond))
+	require.NoError(t, err)
+	_, err = c.Get(context.Background(), 1, 1)
+	assert.ErrorIs(t, err, client.ErrUnavailable)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// TestClientNotFound проверяет, что отсутствующее событие - ErrNotFound сразу, без повторов
+func TestClientNotFound(t *testing.T) {
+
+	var calls atomic.Int32
+	server, _ := newAPIServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			next.ServeHTTP(w, r)
+		})
+	})
+	c, err := client.New(server.URL, client.WithHTTPClient(server.Client()), client.WithRetries(2, time.Second))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	_, err = c.Get(ctx, 42, 1)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.NotErrorIs(t, err, client.ErrUnavailable)
+
+	_, err = c.Update(ctx, &client.Event{ID: 1, UserID: 42, Date: date, Title: "Нет такого", Version: 1})
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	err = c.Delete(ctx, 42, 1, 1)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.Equal(t, int32(3), calls.Load(), "Ошибки 404 не повторяются")
+
+	// у пользователя без событий - пустой список, а не ошибка
+	events, err := c.EventsForDay(ctx, 42, date)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+// TestClientRetryUpdate проверяет, что повтор потерянного ответа на обновление не даёт конфликт версий
+func TestClientRetryUpdate(t *testing.T) {
+
+	var lose atomic.Bool
+	server, _ := newAPIServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && lose.CompareAndSwap(true, false) {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c, err := client.New(server.URL, client.WithHTTPClient(server.Client()), client.WithRetries(1, time.Millisecond))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	event, err := c.Create(ctx, 1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "")
+	require.NoError(t, err)
+
+	lose.Store(true)
+	event.Title = "Перенесли"
+	updated, err := c.Update(ctx, event)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	lose.Store(true)
+	require.NoError(t, c.Delete(ctx, 1, event.ID, updated.Version))
+	_, err = c.Get(ctx, 1, event.ID)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
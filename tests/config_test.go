@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile создаёт файл с содержимым во временной папке теста
+func writeFile(t *testing.T, name, content string) string {
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	return path
+}
+
+// TestConfigPrecedence проверяет порядок источников: по умолчанию < файл < окружение < флаги
+func TestConfigPrecedence(t *testing.T) {
+
+	// без источников - значения по умолчанию (.env в папке tests нет)
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+
+	// пример из репозитория описывает те же значения по умолчанию
+	cfg, err = config.Load([]string{"-config", "../config.example.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+
+	path := writeFile(t, "calendar.yaml", `
+server:
+  port: "9000"
+  grpc_port: "off"
+  shutdown_timeout: 10s
+log:
+  dir: /var/log/calendar
+  format: json
+storage:
+  trash_retention: 168h
+`)
+	t.Setenv("CALENDAR_CONFIG", path)
+	t.Setenv("CALENDAR_PORT", "9100")
+	t.Setenv("CALENDAR_LOG_FORMAT", "text")
+
+	cfg, err = config.Load([]string{"-port", "9200", "-log-level", "debug"})
+	require.NoError(t, err)
+	assert.Equal(t, "9200", cfg.Server.Port, "Флаг перекрывает окружение и файл")
+	assert.Equal(t, "text", cfg.Log.Format, "Окружение перекрывает файл")
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, "/var/log/calendar", cfg.Log.Dir, "Значение из файла")
+	assert.Equal(t, 10*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, 7*24*time.Hour, cfg.Storage.TrashRetention)
+	assert.Empty(t, cfg.Server.GRPCPort, "off выключает gRPC")
+	assert.Equal(t, 30, cfg.Log.MaxFiles, "Не заданное нигде - по умолчанию")
+
+	// TOML и файл из флага вместо CALENDAR_CONFIG
+	path = writeFile(t, "calendar.toml", `
+[server]
+drain_delay = "1s"
+
+[trace]
+exporter = "stdout"
+sample_ratio = 0.5
+`)
+	cfg, err = config.Load([]string{"-config", path})
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, cfg.Server.DrainDelay)
+	assert.Equal(t, "stdout", cfg.Trace.Exporter)
+	assert.Equal(t, 0.5, cfg.Trace.SampleRatio)
+
+	// по умолчанию трассируются все запросы, 0 - ни одного
+	assert.Equal(t, 1.0, config.Default().Trace.SampleRatio)
+	cfg, err = config.Load([]string{"-trace-sample-ratio", "0"})
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Trace.SampleRatio)
+}
+
+// TestConfigEnvFile проверяет чтение .env: переменные окружения важнее файла
+func TestConfigEnvFile(t *testing.T) {
+
+	path := writeFile(t, "test.env", "CALENDAR_LOG_MAX_FILES=7\nCALENDAR_GRPC_PORT=\"9500\"\n")
+	t.Setenv("CALENDAR_GRPC_PORT", "9600")
+	t.Cleanup(func() { os.Unsetenv("CALENDAR_LOG_MAX_FILES") })
+
+	cfg, err := config.Load([]string{"-env-file", path})
+	require.NoError(t, err)
+	assert.Equal(t, 7, cfg.Log.MaxFiles)
+	assert.Equal(t, "9600", cfg.Server.GRPCPort)
+
+	// явно указанный файл обязан существовать
+	_, err = config.Load([]string{"-env-file", filepath.Join(t.TempDir(), "missing.env")})
+	assert.Error(t, err)
+}
+
+// TestConfigValidation проверяет ошибки в настройках
+func TestConfigValidation(t *testing.T) {
+
+	// неизвестный ключ в файле
+	path := writeFile(t, "calendar.yaml", "server:\n  prot: \"9000\"\n")
+	_, err := config.Load([]string{"-config", path})
+	assert.ErrorContains(t, err, "prot")
+
+	path = writeFile(t, "calendar.toml", "[log]\nformatt = \"json\"\n")
+	_, err = config.Load([]string{"-config", path})
+	assert.ErrorContains(t, err, "formatt")
+
+	_, err = config.Load([]string{"-config", writeFile(t, "calendar.ini", "")})
+	assert.Error(t, err)
+
+	// неверное значение в окружении
+	t.Setenv("CALENDAR_SHUTDOWN_TIMEOUT", "скоро")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "CALENDAR_SHUTDOWN_TIMEOUT")
+	os.Unsetenv("CALENDAR_SHUTDOWN_TIMEOUT")
+
+	// все ошибки проверки сообщаются сразу
+	_, err = config.Load([]string{"-port", "70000", "-log-format", "xml", "-trace-sample-ratio", "2"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "log.format")
+	assert.Contains(t, err.Error(), "trace.sample_ratio")
+
+	// TLS: ключ обязателен, h2c только без TLS, для mTLS нужен CA
+	_, err = config.Load([]string{"-tls-cert", "server.pem"})
+	assert.ErrorContains(t, err, "key_file")
+	_, err = config.Load([]string{"-tls-cert", "server.pem", "-tls-key", "server.key", "-h2c"})
+	assert.ErrorContains(t, err, "h2c")
+	_, err = config.Load([]string{"-tls-cert", "server.pem", "-tls-key", "server.key", "-tls-client-auth", "require"})
+	assert.ErrorContains(t, err, "client_ca_file")
+
+	// лимиты и квоты не бывают отрицательными
+	_, err = config.Load([]string{"-rate-limit-user", "-1", "-max-events-per-user", "-5"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.rate_limit.user_rate")
+	assert.Contains(t, err.Error(), "storage.max_events_per_user")
+
+	_, err = config.Load([]string{"-no-such-flag"})
+	assert.Error(t, err)
+	_, err = config.Load([]string{"-h"})
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/grpcapi"
+	"github.com/IPampurin/calendar-server/pkg/grpcapi/calendarpb"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient поднимает gRPC-сервис в памяти и возвращает клиента к нему
+func newGRPCClient(t *testing.T, db storage.Repository) (calendarpb.CalendarServiceClient, *grpcapi.Server) {
+
+	listener := bufconn.Listen(1024 * 1024)
+	service := grpcapi.NewServer(db)
+	srv := grpc.NewServer()
+	service.Register(srv)
+	go srv.Serve(listener)
+	t.Cleanup(func() {
+		service.Close()
+		srv.GracefulStop()
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return calendarpb.NewCalendarServiceClient(conn), service
+}
+
+func TestGRPCService(t *testing.T) {
+
+	client, _ := newGRPCClient(t, storage.NewStorage())
+	ctx := context.Background()
+
+	created, err := client.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "2026-01-15", Title: "Встреча"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.GetId())
+	assert.Equal(t, int64(1), created.GetVersion())
+	assert.NotNil(t, created.GetCreatedAt())
+
+	// обновление с проверкой версии
+	created.Title = "Перенесли"
+	updated, err := client.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{Event: created, ActorId: 7})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.GetVersion())
+
+	_, err = client.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{Event: created})
+	assert.Equal(t, codes.Aborted, status.Code(err), "Устаревшая версия")
+
+	// без версии изменение не выполняется, как без If-Match в API v2
+	_, err = client.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{Event: &calendarpb.Event{Id: 1, UserId: 1, Date: "2026-01-15", Title: "Без версии"}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.DeleteEvent(ctx, &calendarpb.DeleteEventRequest{UserId: 1, EventId: 1})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "15.01.2026", Title: "Плохая дата"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := client.ListEvents(ctx, &calendarpb.ListEventsRequest{UserId: 1, Date: "2026-01-01", Period: calendarpb.Period_PERIOD_MONTH})
+	require.NoError(t, err)
+	require.Len(t, list.GetEvents(), 1)
+	assert.Equal(t, "Перенесли", list.GetEvents()[0].GetTitle())
+
+	empty, err := client.ListEvents(ctx, &calendarpb.ListEventsRequest{UserId: 2, Date: "2026-01-01"})
+	require.NoError(t, err)
+	assert.Empty(t, empty.GetEvents(), "У пользователя без событий пустой список")
+
+	history, err := client.GetHistory(ctx, &calendarpb.EventRef{UserId: 1, EventId: 1})
+	require.NoError(t, err)
+	require.Len(t, history.GetRevisions(), 2)
+	assert.Equal(t, int64(7), history.GetRevisions()[1].GetActorId())
+	assert.Equal(t, "title", history.GetRevisions()[1].GetDiff()[0].GetField())
+
+	// удаление, корзина, восстановление
+	_, err = client.DeleteEvent(ctx, &calendarpb.DeleteEventRequest{UserId: 1, EventId: 1, Version: 2})
+	require.NoError(t, err)
+	_, err = client.GetEvent(ctx, &calendarpb.EventRef{UserId: 1, EventId: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	trash, err := client.ListTrash(ctx, &calendarpb.ListTrashRequest{UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, trash.GetItems(), 1)
+
+	restored, err := client.RestoreEvent(ctx, &calendarpb.EventRef{UserId: 1, EventId: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Перенесли", restored.GetTitle())
+
+	sync, err := client.Sync(ctx, &calendarpb.SyncRequest{UserId: 1})
+	require.NoError(t, err)
+	assert.True(t, sync.GetFull())
+	assert.Len(t, sync.GetEvents(), 1)
+	assert.NotEmpty(t, sync.GetSyncToken())
+}
+
+func TestGRPCWatchChanges(t *testing.T) {
+
+	db := storage.NewStorage()
+	client, service := newGRPCClient(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchChanges(ctx, &calendarpb.WatchChangesRequest{UserId: 1, Types: []string{"event.created"}})
+	require.NoError(t, err)
+
+	changes := make(chan *calendarpb.Change, 16)
+	go func() {
+		for {
+			change, err := stream.Recv()
+			if err != nil {
+				close(changes)
+				return
+			}
+			changes <- change
+		}
+	}()
+
+	// поток регистрируется на сервере не мгновенно - создаём события, пока одно не придёт
+	date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	var change *calendarpb.Change
+	for attempt := 0; attempt < 100 && change == nil; attempt++ {
+		_, err := db.Create(3, date, "Чужое", "")
+		require.NoError(t, err)
+		_, err = db.Create(1, date, "Встреча", "")
+		require.NoError(t, err)
+		select {
+		case change = <-changes:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, change, "Изменение не пришло в поток")
+	assert.Equal(t, "event.created", change.GetType())
+	assert.Equal(t, int64(1), change.GetUserId(), "Изменения других пользователей не приходят")
+	assert.Equal(t, "Встреча", change.GetEvent().GetTitle())
+
+	// остановка сервера завершает поток
+	service.Close()
+	for range changes {
+	}
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/health"
+	"github.com/IPampurin/calendar-server/pkg/server"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHealthEndpoints проверяет /healthz и /readyz сервера, в том числе при остановке
+func TestHealthEndpoints(t *testing.T) {
+
+	db := storage.NewStorage()
+	srv, err := server.New(db, server.Config{
+		WebhookOutbox: t.TempDir() + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	require.NoError(t, err)
+	handler := srv.Handler()
+
+	probe := func(target string) (int, health.Report) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	code, report := probe("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+
+	code, report = probe("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"storage": "ok"}, report.Checks)
+
+	// своя проверка, которая не проходит
+	srv.Health().Register("queue", func(context.Context) error { return errors.New("очередь недоступна") })
+	code, report = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, "очередь недоступна", report.Checks["queue"])
+	srv.Health().Register("queue", func(context.Context) error { return nil })
+
+	// хранилище заблокировано - проверка не зависает
+	db.Mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+	db.Mu.Unlock()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	code, _ = probe("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// после Drain сервер жив, но не готов
+	srv.Drain()
+	code, report = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, report.Checks, "shutdown")
+	code, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
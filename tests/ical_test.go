@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/ical"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICalRoundTrip(t *testing.T) {
+
+	events := []*storage.Event{
+		{ID: 1, UserID: 1, Date: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), Title: "Встреча; планёрка, утро", Content: "Строка 1\nСтрока 2 \\ конец"},
+		{ID: 2, UserID: 1, Date: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Title: strings.Repeat("Очень длинный заголовок ", 10)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, ical.Encode(&buf, events))
+
+	// строки не длиннее 75 октетов и заканчиваются CRLF
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, "Строка %q не перенесена", line)
+	}
+
+	decoded, err := ical.Decode(&buf)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	for i := range events {
+		assert.True(t, events[i].Date.Equal(decoded[i].Date))
+		assert.Equal(t, events[i].Title, decoded[i].Title)
+		assert.Equal(t, events[i].Content, decoded[i].Content)
+	}
+}
+
+func TestICalDecode(t *testing.T) {
+
+	// календарь из стороннего приложения: дата-время, LF вместо CRLF, лишние свойства
+	data := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:abc\nDTSTART;TZID=Europe/Moscow:20260315T100000\n" +
+		"SUMMARY:Созвон\nLOCATION:Офис\nEND:VEVENT\nBEGIN:VEVENT\nDTSTART:20260316T070000Z\nSUMMARY:Ещё\n  один\nEND:VEVENT\nEND:VCALENDAR\n"
+
+	events, err := ical.Decode(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "Созвон", events[0].Title)
+	assert.True(t, events[0].Date.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "Ещё один", events[1].Title)
+
+	_, err = ical.Decode(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Без даты\nEND:VEVENT\nEND:VCALENDAR\n"))
+	assert.Error(t, err, "Событие без DTSTART")
+}
+
+func TestICalDecodeNested(t *testing.T) {
+
+	// свойства напоминания (VALARM) не должны затирать свойства события,
+	// а ':' внутри параметра в кавычках - обрывать имя свойства
+	data := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20260315\nSUMMARY:Созвон\n" +
+		"DESCRIPTION;ALTREP=\"http://example.com/a;b:c\":Повестка\n" +
+		"BEGIN:VALARM\nACTION:DISPLAY\nTRIGGER:-PT15M\nSUMMARY:Напоминание\nDESCRIPTION:Скоро созвон\n" +
+		"DTSTART:20260101T000000Z\nEND:VALARM\nLOCATION:Офис\nEND:VEVENT\nEND:VCALENDAR\n"
+
+	events, err := ical.Decode(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Созвон", events[0].Title)
+	assert.Equal(t, "Повестка", events[0].Content)
+	assert.True(t, events[0].Date.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)))
+
+	_, err = ical.Decode(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20260315\nSUMMARY:Ок\nBEGIN:VALARM\nEND:VEVENT\nEND:VCALENDAR\n"))
+	assert.Error(t, err, "Не закрытый VALARM")
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IPampurin/calendar-server/pkg/server"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetrics проверяет /metrics: запросы по маршрутам, вызовы хранилища и размер хранилища
+func TestMetrics(t *testing.T) {
+
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		WebhookOutbox: t.TempDir() + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	require.NoError(t, err)
+	handler := srv.Handler()
+
+	do := func(method, target, body string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusCreated, do("POST", "/create_event", `{"user_id":1,"date":"2026-01-15","title":"Встреча"}`))
+	require.Equal(t, http.StatusCreated, do("POST", "/create_event", `{"user_id":2,"date":"2026-01-15","title":"Обед"}`))
+	require.Equal(t, http.StatusNotFound, do("GET", "/v2/users/1/events/99", ""))
+	require.Equal(t, http.StatusNotFound, do("GET", "/no_such_route", ""))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	metrics := rec.Body.String()
+
+	// запросы по шаблону маршрута, ID в метки не попадают
+	assert.Contains(t, metrics, `calendar_http_requests_total{method="POST",route="/create_event",status="201"} 2`)
+	assert.Contains(t, metrics, `calendar_http_requests_total{method="GET",route="/v2/users/{id}/events/{eventID}",status="404"} 1`)
+	assert.Contains(t, metrics, `calendar_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, metrics, `calendar_http_request_duration_seconds_count{method="POST",route="/create_event",status="201"} 2`)
+
+	// вызовы хранилища
+	assert.Contains(t, metrics, `calendar_storage_operations_total{method="Create",result="ok"} 2`)
+	assert.Contains(t, metrics, `calendar_storage_operations_total{method="Get",result="not_found"} 1`)
+	assert.Contains(t, metrics, `calendar_storage_operation_duration_seconds_count{method="Create"} 2`)
+
+	// размер хранилища
+	assert.Contains(t, metrics, "calendar_storage_users 2")
+	assert.Contains(t, metrics, "calendar_storage_events 2")
+	assert.Contains(t, metrics, "calendar_storage_trashed_events 0")
+
+	// стандартные метрики процесса
+	assert.Contains(t, metrics, "go_goroutines")
+}
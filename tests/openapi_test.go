@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPISpecCoversRoutes падает, если зарегистрированный маршрут не описан в спецификации
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(api.OpenAPISpec(), &spec), "openapi.json должен быть валидным JSON")
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3.1"), "Ожидается OpenAPI 3.1")
+
+	apiMock := api.NewAPI(newMockStorage())
+	routes := apiMock.Routes()
+	require.NotEmpty(t, routes)
+
+	described := make(map[string]bool)
+	for _, route := range routes {
+		method, path, ok := strings.Cut(route.Pattern, " ")
+		require.True(t, ok, "Маршрут %q без метода", route.Pattern)
+
+		operations, ok := spec.Paths[path]
+		if !assert.True(t, ok, "Путь %s не описан в openapi.json", path) {
+			continue
+		}
+		_, ok = operations[strings.ToLower(method)]
+		assert.True(t, ok, "Метод %s %s не описан в openapi.json", method, path)
+		described[strings.ToLower(method)+" "+path] = true
+	}
+
+	// и наоборот: в спецификации нет маршрутов, которых нет на сервере
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, described[method+" "+path], "В openapi.json описан несуществующий маршрут %s %s", method, path)
+		}
+	}
+}
+
+// TestOpenAPIHandlers проверяет, что спецификация и документация отдаются сервером
+func TestOpenAPIHandlers(t *testing.T) {
+
+	apiMock := api.NewAPI(newMockStorage())
+
+	rec := httptest.NewRecorder()
+	apiMock.OpenAPIHandler(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, api.OpenAPISpec(), rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	apiMock.DocsHandler(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "openapi.json")
+	assert.NotContains(t, rec.Body.String(), "https://", "Страница не должна тянуть скрипты с CDN")
+}
+
+// TestDocsAssets проверяет, что скрипт и стили Swagger UI отдаются самим сервером, в том числе с префиксом
+func TestDocsAssets(t *testing.T) {
+
+	server := httptest.NewServer(api.NewHandler(newMockStorage(), api.WithPrefix("/api")))
+	defer server.Close()
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"/api/docs/swagger-ui-bundle.js", http.StatusOK, "javascript", "SwaggerUIBundle"},
+		{"/api/docs/swagger-ui.css", http.StatusOK, "text/css", ".swagger-ui"},
+		{"/api/docs/LICENSE", http.StatusNotFound, "application/json", ""},
+		{"/api/docs/..%2Fopenapi.json", http.StatusNotFound, "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := server.Client().Get(server.URL + tt.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Contains(t, resp.Header.Get("Content-Type"), tt.contentType)
+			assert.Contains(t, string(body), tt.contains)
+		})
+	}
+}
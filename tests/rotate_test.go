@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock - управляемое время для ротации
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// listLogs возвращает имена файлов в папке логов
+func listLogs(t *testing.T, dir string) []string {
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// readGzip возвращает содержимое сжатого файла
+func readGzip(t *testing.T, name string) string {
+
+	file, err := os.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+// TestRotatingWriterDaily проверяет переключение файла в полночь и сжатие старого
+func TestRotatingWriterDaily(t *testing.T) {
+
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2026, 1, 15, 23, 59, 0, 0, time.Local)}
+
+	w, err := server.NewRotatingWriter(server.RotateConfig{Dir: dir, Compress: true, Now: clock.Now})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("вечер\n"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "calendar_2026-01-15.log"), w.Filename())
+
+	clock.Set(time.Date(2026, 1, 16, 0, 0, 1, 0, time.Local))
+	_, err = w.Write([]byte("утро\n"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "calendar_2026-01-16.log"), w.Filename())
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"calendar_2026-01-15.log.gz", "calendar_2026-01-16.log"}, listLogs(t, dir))
+	assert.Equal(t, "вечер\n", readGzip(t, filepath.Join(dir, "calendar_2026-01-15.log.gz")))
+
+	data, err := os.ReadFile(filepath.Join(dir, "calendar_2026-01-16.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "утро\n", string(data))
+
+	_, err = w.Write([]byte("после закрытия\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+// TestRotatingWriterSizeAndRetention проверяет ротацию по размеру и удаление лишних файлов
+func TestRotatingWriterSizeAndRetention(t *testing.T) {
+
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2026, 1, 15, 12, 0, 0, 0, time.Local)}
+
+	w, err := server.NewRotatingWriter(server.RotateConfig{Dir: dir, MaxSize: 10, MaxFiles: 2, Now: clock.Now})
+	require.NoError(t, err)
+
+	for _, line := range []string{"первая\n", "вторая\n", "третья\n", "четвёртая\n"} {
+		_, err = w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	// текущий файл и два последних переполненных, самый первый удалён
+	assert.Equal(t, []string{"calendar_2026-01-15.2.log", "calendar_2026-01-15.3.log", "calendar_2026-01-15.log"}, listLogs(t, dir))
+
+	data, err := os.ReadFile(filepath.Join(dir, "calendar_2026-01-15.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "четвёртая\n", string(data))
+}
+
+// TestRotatingWriterMaxAge проверяет удаление старых файлов при запуске
+func TestRotatingWriterMaxAge(t *testing.T) {
+
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.Local)
+
+	old := filepath.Join(dir, "calendar_2025-12-01.log")
+	recent := filepath.Join(dir, "calendar_2026-01-14.log")
+	other := filepath.Join(dir, "other.log")
+	for _, name := range []string{old, recent, other} {
+		require.NoError(t, os.WriteFile(name, []byte("x\n"), 0644))
+	}
+	require.NoError(t, os.Chtimes(old, now.AddDate(0, 0, -45), now.AddDate(0, 0, -45)))
+	require.NoError(t, os.Chtimes(recent, now.AddDate(0, 0, -1), now.AddDate(0, 0, -1)))
+
+	w, err := server.NewRotatingWriter(server.RotateConfig{
+		Dir:    dir,
+		MaxAge: 30 * 24 * time.Hour,
+		Now:    func() time.Time { return now },
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"calendar_2026-01-14.log", "calendar_2026-01-15.log", "other.log"}, listLogs(t, dir))
+}
+
+// TestRotatingWriterReopen проверяет переоткрытие файла после внешнего logrotate
+func TestRotatingWriterReopen(t *testing.T) {
+
+	dir := t.TempDir()
+	w, err := server.NewRotatingWriter(server.RotateConfig{Dir: dir})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("до\n"))
+	require.NoError(t, err)
+
+	// logrotate переименовал файл, процесс продолжал бы писать в него
+	moved := filepath.Join(dir, "moved.log")
+	require.NoError(t, os.Rename(w.Filename(), moved))
+	require.NoError(t, w.Reopen())
+
+	_, err = w.Write([]byte("после\n"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(moved)
+	require.NoError(t, err)
+	assert.Equal(t, "до\n", string(data))
+
+	data, err = os.ReadFile(w.Filename())
+	require.NoError(t, err)
+	assert.Equal(t, "после\n", string(data))
+}
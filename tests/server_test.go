@@ -3,14 +3,22 @@ package tests
 import (
 	"bytes"
 	"context"
-	"log"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/IPampurin/calendar-server/pkg/config"
 	"github.com/IPampurin/calendar-server/pkg/server"
 	"github.com/IPampurin/calendar-server/pkg/storage"
 	"github.com/stretchr/testify/assert"
@@ -95,7 +103,7 @@ func TestLoggingMiddleware(t *testing.T) {
 
 	// перехватываем вывод лога в буфер, чтобы потом проверить, что туда записалось
 	var buf bytes.Buffer
-	logger := log.New(&buf, "", 0)
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
 
 	// тестовый обработчик — имитируем реальный хендлер API
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -120,17 +128,464 @@ func TestLoggingMiddleware(t *testing.T) {
 	assert.Contains(t, logOutput, "200")
 }
 
+// TestLoggingMiddlewareJSON проверяет поля JSON-лога доступа
+func TestLoggingMiddlewareJSON(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger, err := server.NewLogger(&buf, "json", "info")
+	require.NoError(t, err)
+
+	var requestID string
+	handler := server.LoggingMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = server.RequestID(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("нет"))
+	}))
+
+	req := httptest.NewRequest("GET", "/events_for_day?user_id=7&token=secret&date=2026-01-15", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.1")
+	req.Header.Set("User-Agent", "calctl/1.0")
+	req.Header.Set(server.HeaderRequestID, "req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-42", requestID, "ID запроса доступен хендлеру")
+	assert.Equal(t, "req-42", rec.Header().Get(server.HeaderRequestID))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "req-42", entry["request_id"])
+	assert.Equal(t, "/events_for_day", entry["path"])
+	assert.EqualValues(t, 404, entry["status"])
+	assert.EqualValues(t, len("нет"), entry["size"])
+	assert.EqualValues(t, 7, entry["user_id"])
+	assert.Equal(t, "203.0.113.5", entry["client_ip"])
+	assert.Equal(t, "calctl/1.0", entry["user_agent"])
+	assert.Equal(t, "date=2026-01-15&token=REDACTED&user_id=7", entry["query"])
+	assert.Contains(t, entry, "latency_ms")
+	assert.NotContains(t, buf.String(), "secret", "Секреты не попадают в лог")
+
+	// v1 передаёт user_id в теле: он попадает в лог, а хендлер получает тело целиком
+	var received string
+	bodyHandler := server.LoggingMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = string(data)
+	}))
+	for _, tt := range []struct {
+		name  string
+		body  string
+		actor string
+		want  any
+	}{
+		{"поле запроса", `{"user_id":9,"date":"2026-01-15","title":"Встреча"}`, "", float64(9)},
+		{"поле важнее X-Actor-ID", `{"user_id":9,"id":1,"title":"Встреча"}`, "3", float64(9)},
+		{"операции пакета", `{"operations":[{"op":"create","user_id":11}]}`, "", float64(11)},
+		{"без user_id - X-Actor-ID", `{"title":"Встреча"}`, "3", float64(3)},
+		{"не JSON", `user_id=9`, "", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest("POST", "/create_event", strings.NewReader(tt.body))
+			if tt.actor != "" {
+				req.Header.Set(api.HeaderActorID, tt.actor)
+			}
+			bodyHandler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.body, received)
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			assert.Equal(t, tt.want, entry["user_id"])
+		})
+	}
+
+	// без заголовка ID генерируется
+	buf.Reset()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Len(t, rec.Header().Get(server.HeaderRequestID), 32)
+
+	// уровень warn отбрасывает успешные запросы
+	buf.Reset()
+	quiet, err := server.NewLogger(&buf, "text", "warn")
+	require.NoError(t, err)
+	server.LoggingMiddleware(quiet)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, buf.String(), "status=404")
+	buf.Reset()
+	server.LoggingMiddleware(quiet)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Empty(t, buf.String())
+
+	_, err = server.NewLogger(&buf, "xml", "")
+	assert.Error(t, err)
+	_, err = server.NewLogger(&buf, "", "loud")
+	assert.Error(t, err)
+}
+
 // TestSetupLogging проверяет создание логгера и файла для логов
 // (убеждается, что функция не возвращает ошибку, а логгер и файл созданы)
 func TestSetupLogging(t *testing.T) {
 
-	// вызываем настройку логирования
-	logger, file, err := server.SetupLogging()
+	// вызываем настройку логирования с настройками по умолчанию во временной папке
+	cfg := config.Default().Log
+	cfg.Dir = t.TempDir()
+	logger, file, err := server.SetupLogging(cfg)
 	require.NoError(t, err, "SetupLogging не должна возвращать ошибку")
 	require.NotNil(t, logger, "логгер должен быть создан")
 	require.NotNil(t, file, "файл логов должен быть открыт")
 
-	// закрываем файл и удаляем временную папку с логами
+	// закрываем файл
 	file.Close()
-	os.RemoveAll("logs")
+
+	// неверный формат обнаруживается до создания файла
+	cfg.Format = "xml"
+	_, _, err = server.SetupLogging(cfg)
+	assert.Error(t, err)
+}
+
+// TestNewHandler проверяет роутер без DefaultServeMux: префикс, middleware, несколько экземпляров
+func TestNewHandler(t *testing.T) {
+
+	calls := 0
+	counter := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	first := storage.NewStorage()
+	second := storage.NewStorage()
+	_, err := first.Create(1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Первое", "")
+	require.NoError(t, err)
+
+	// два независимых экземпляра в одном процессе
+	mux := http.NewServeMux()
+	mux.Handle("/calendar/", api.NewHandler(first, api.WithPrefix("/calendar"), api.WithMiddleware(counter)))
+	mux.Handle("/other/", api.NewHandler(second, api.WithPrefix("other")))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calendar/events_for_day?user_id=1&date=2026-01-15", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Первое")
+	assert.Equal(t, 1, calls, "Middleware вызывается для запросов экземпляра")
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other/events_for_day?user_id=1&date=2026-01-15", nil))
+	assert.NotContains(t, rec.Body.String(), "Первое", "Экземпляры не делят хранилище")
+	assert.Equal(t, 1, calls)
+
+	// без префикса маршрут не найден
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events_for_day?user_id=1&date=2026-01-15", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Location созданного ресурса тоже с префиксом
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/calendar/v2/users/1/events", strings.NewReader(`{"date":"2026-01-16","title":"Второе"}`)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/calendar/v2/users/1/events/2", rec.Header().Get("Location"))
+
+	// спецификация указывает на префикс
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calendar/openapi.json", nil))
+	assert.Contains(t, rec.Body.String(), `"url": "/calendar"`)
+}
+
+// TestServerStartStop запускает встроенный сервер на свободных портах и останавливает его
+func TestServerStartStop(t *testing.T) {
+
+	var buf bytes.Buffer
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		Port:          "0",
+		GRPCPort:      "0",
+		WebhookOutbox: t.TempDir() + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(&buf, nil)),
+	})
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	assert.Error(t, srv.Start(), "Повторный запуск")
+	assert.NotEmpty(t, srv.GRPCAddr())
+
+	resp, err := http.Post("http://"+srv.Addr()+"/create_event", "application/json",
+		bytes.NewBufferString(`{"user_id":1,"date":"2026-01-15","title":"Встреча"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, buf.String(), "method=POST path=/create_event status=201", "Запросы попадают в журнал")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Stop(ctx))
+	require.NoError(t, srv.Stop(ctx), "Повторная остановка ничего не делает")
+
+	_, err = http.Get("http://" + srv.Addr() + "/events_for_day")
+	assert.Error(t, err, "После остановки порт закрыт")
+}
+
+// TestServersShareStorage проверяет два сервера в одном процессе над общим хранилищем:
+// у каждого свои подписки на вебхуки, остановленный сервер не получает изменений хранилища
+func TestServersShareStorage(t *testing.T) {
+
+	db := storage.NewStorage()
+	newServer := func() *server.Server {
+		srv, err := server.New(db, server.Config{Port: "0", Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+		require.NoError(t, err)
+		require.NoError(t, srv.Start())
+		return srv
+	}
+	first, second := newServer(), newServer()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer second.Stop(ctx)
+
+	do := func(srv *server.Server, method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	deliveries := func() int {
+		rec := do(first, "GET", "/webhook_deliveries?id=1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var answer struct {
+			Result []json.RawMessage `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&answer))
+		return len(answer.Result)
+	}
+
+	// outbox по умолчанию в памяти: подписка первого сервера не видна второму
+	rec := do(first, "POST", "/create_webhook", `{"url":"http://127.0.0.1:1/hook","user_id":1}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = do(second, "GET", "/webhooks?user_id=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "127.0.0.1:1")
+	_, err := os.Stat("data/webhooks.json")
+	assert.True(t, os.IsNotExist(err), "Без WebhookOutbox файл не создаётся")
+
+	// изменение через второй сервер попадает в outbox первого, пока тот работает
+	require.Equal(t, http.StatusCreated, do(second, "POST", "/create_event", `{"user_id":1,"date":"2026-01-15","title":"Встреча"}`).Code)
+	require.Equal(t, 1, deliveries())
+
+	require.NoError(t, first.Stop(ctx))
+	require.Equal(t, http.StatusCreated, do(second, "POST", "/create_event", `{"user_id":1,"date":"2026-01-16","title":"Обед"}`).Code)
+	assert.Equal(t, 1, deliveries(), "Остановленный сервер отписан от хранилища")
+}
+
+// TestRecoveryMiddleware проверяет, что паника в хэндлере даёт 500 с ID запроса и стек в журнале
+func TestRecoveryMiddleware(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := server.LoggingMiddleware(logger)(server.RecoveryMiddleware(logger)(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("что-то сломалось")
+		}),
+	))
+
+	req := httptest.NewRequest("GET", "/event?user_id=1&id=1", nil)
+	req.Header.Set(server.HeaderRequestID, "req-42")
+	rec := httptest.NewRecorder()
+	require.NotPanics(t, func() { handler.ServeHTTP(rec, req) })
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var answer api.Answer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+	assert.NotEmpty(t, answer.Error)
+	assert.Equal(t, "req-42", answer.RequestID)
+
+	logs := buf.String()
+	assert.Contains(t, logs, `"msg":"panic"`)
+	assert.Contains(t, logs, "что-то сломалось")
+	assert.Contains(t, logs, "TestRecoveryMiddleware", "В журнале есть стек")
+	assert.Contains(t, logs, `"status":500`, "Запрос попал в журнал со статусом 500")
+}
+
+// TestMaxBodySize проверяет ответ 413 на слишком большое тело запроса
+func TestMaxBodySize(t *testing.T) {
+
+	handler := api.NewHandler(storage.NewStorage(), api.WithMaxBodySize(64))
+	body := `{"user_id":1,"date":"2026-01-15","title":"` + strings.Repeat("а", 100) + `"}`
+
+	// размер известен заранее - отказ без чтения тела
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/create_event", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), "64")
+
+	// размер неизвестен (chunked) - отказ при чтении, в том числе в v2 и с Idempotency-Key
+	for _, target := range []string{"/create_event", "/v2/users/1/events"} {
+		req := httptest.NewRequest("POST", target, io.MultiReader(strings.NewReader(body)))
+		req.ContentLength = -1
+		req.Header.Set(api.HeaderIdempotencyKey, "key-"+target)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, target)
+
+		req = httptest.NewRequest("POST", target, io.MultiReader(strings.NewReader(body)))
+		req.ContentLength = -1
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, target)
+	}
+
+	// в пределах лимита - как обычно
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/create_event", strings.NewReader(`{"user_id":1,"date":"2026-01-15","title":"Да"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+// TestServerTimeouts проверяет, что соединение без заголовков закрывается по ReadHeaderTimeout
+func TestServerTimeouts(t *testing.T) {
+
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		Port:              "0",
+		WebhookOutbox:     t.TempDir() + "/webhooks.json",
+		Logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ReadHeaderTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /healthz HTTP/1.1\r\nHost: localhost\r\n")) // заголовки не дописаны
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	start := time.Now()
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err, "Сервер сам закрывает соединение")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+// TestQuotaStatus проверяет ответы API на превышение квот хранилища
+func TestQuotaStatus(t *testing.T) {
+
+	db := storage.NewStorage()
+	db.MaxEventsPerUser = 1
+	db.MaxTitleLength = 10
+	handler := api.NewHandler(db)
+
+	post := func(target, body string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/create_event", `{"user_id":1,"date":"2026-01-15","title":"Очень длинное название"}`))
+	assert.Equal(t, http.StatusCreated, post("/create_event", `{"user_id":1,"date":"2026-01-15","title":"Встреча"}`))
+	assert.Equal(t, http.StatusTooManyRequests, post("/create_event", `{"user_id":1,"date":"2026-01-16","title":"Встреча"}`))
+	assert.Equal(t, http.StatusTooManyRequests, post("/v2/users/1/events", `{"date":"2026-01-16","title":"Встреча"}`))
+	assert.Equal(t, http.StatusCreated, post("/v2/users/2/events", `{"date":"2026-01-16","title":"Встреча"}`))
+}
+
+// TestRateLimitMiddleware проверяет ограничение частоты запросов по IP и по пользователю
+func TestRateLimitMiddleware(t *testing.T) {
+
+	var received string
+	handler := server.RateLimitMiddleware(server.RateLimitConfig{UserRate: 0.5, UserBurst: 2, IPRate: 0.5, IPBurst: 4})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received = string(body)
+		}))
+
+	request := func(remoteAddr, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// пользователь v2 из пути упирается в свой лимит раньше, чем IP
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1000", "GET", "/v2/users/1/events", "").Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1001", "GET", "/v2/users/1/events", "").Code)
+	rec := request("10.0.0.1:1002", "GET", "/v2/users/1/events", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"), "Токен появится через 2 секунды")
+	assert.Contains(t, rec.Body.String(), "повторите")
+
+	// другой пользователь с того же IP проходит, пока не кончится лимит IP
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1003", "GET", "/v2/users/2/events", "").Code)
+	rec = request("10.0.0.1:1004", "GET", "/v2/users/3/events", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// другой IP не затронут, X-Forwarded-For без trust_proxy не помогает
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1000", "GET", "/v2/users/3/events", "").Code)
+	req := httptest.NewRequest("GET", "/v2/users/4/events", nil)
+	req.RemoteAddr = "10.0.0.1:1005"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// v1: пользователь из тела, хэндлер получает тело целиком
+	body := `{"user_id":5,"date":"2026-01-15","title":"Встреча"}`
+	assert.Equal(t, http.StatusOK, request("10.0.1.1:1000", "POST", "/create_event", body).Code)
+	assert.Equal(t, body, received)
+	assert.Equal(t, http.StatusOK, request("10.0.1.2:1000", "POST", "/create_event", body).Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.1.3:1000", "POST", "/create_event", body).Code)
+
+	// ?user_id и X-Actor-ID клиент выбирает сам - чужой бакет ими не занять
+	req = httptest.NewRequest("GET", "/events_for_day?user_id=5&date=2026-01-15", nil)
+	req.RemoteAddr = "10.0.1.4:1000"
+	req.Header.Set(api.HeaderActorID, "5")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// TestRateLimitV1AndV2 проверяет, что пачка созданий событий одного пользователя ограничивается
+// одинаково через v1 (user_id в теле) и v2 (user_id в пути), а пробы лимит не трогает
+func TestRateLimitV1AndV2(t *testing.T) {
+
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		WebhookOutbox: t.TempDir() + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		RateLimit:     server.RateLimitConfig{UserRate: 0.001, UserBurst: 1},
+	})
+	require.NoError(t, err)
+
+	burst := func(target, body string) []int {
+		var codes []int
+		for range 5 {
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, httptest.NewRequest("POST", target, strings.NewReader(body)))
+			codes = append(codes, rec.Code)
+		}
+		return codes
+	}
+
+	limited := []int{http.StatusCreated, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	assert.Equal(t, limited, burst("/create_event", `{"user_id":1,"date":"2026-01-15","title":"Встреча"}`), "v1")
+	assert.Equal(t, limited, burst("/v2/users/2/events", `{"date":"2026-01-15","title":"Встреча"}`), "v2")
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+// TestRateLimitCertUser проверяет, что пользователь из сертификата клиента важнее user_id запроса
+func TestRateLimitCertUser(t *testing.T) {
+
+	handler := server.ClientCertMiddleware(server.TLSConfig{}, 0)(server.RateLimitMiddleware(server.RateLimitConfig{UserRate: 0.001, UserBurst: 1})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "7"}}
+	request := func(target string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("/v2/users/7/events"))
+	assert.Equal(t, http.StatusTooManyRequests, request("/events_for_day?user_id=7&date=2026-01-15"), "Тот же бакет без user_id в пути")
 }
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
@@ -86,6 +87,11 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, "New content", events[0].Content, "Содержание не обновилось")
 	assert.True(t, events[0].Date.Equal(newDate), "Дата не обновилась")
 
+	// в переданное событие записано сохранённое состояние
+	assert.Equal(t, 2, updatedEvent.Version, "Update должен вернуть новую версию в event")
+	assert.Equal(t, events[0].UpdatedAt, updatedEvent.UpdatedAt)
+	assert.False(t, updatedEvent.CreatedAt.IsZero(), "Update должен заполнить время создания")
+
 	// попытка обновить несуществующее событие
 	t.Log("Проверка: обновление несуществующего события")
 	err = s.Update(&storage.Event{ID: 999, UserID: 1})
@@ -291,3 +297,407 @@ func TestConcurrentAccess(t *testing.T) {
 		ids[event.ID] = true
 	}
 }
+
+// TestSync проверяет инкрементальную синхронизацию по токенам
+func TestSync(t *testing.T) {
+
+	s := storage.NewStorage()
+	s.ChangeLogLimit = 3
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	id1, err := s.Create(1, date, "Первое", "")
+	require.NoError(t, err)
+	id2, err := s.Create(1, date, "Второе", "")
+	require.NoError(t, err)
+	_, err = s.Create(2, date, "Чужое", "")
+	require.NoError(t, err)
+
+	// первая синхронизация - полный снимок
+	t.Log("Полная синхронизация без токена")
+	full, err := s.Sync(1, "")
+	require.NoError(t, err)
+	assert.True(t, full.Full, "Без токена должен вернуться полный снимок")
+	assert.Len(t, full.Events, 2, "Должны вернуться только события пользователя")
+	require.NotEmpty(t, full.SyncToken)
+
+	// ничего не менялось - пустой ответ
+	empty, err := s.Sync(1, full.SyncToken)
+	require.NoError(t, err)
+	assert.False(t, empty.Full)
+	assert.Empty(t, empty.Events)
+	assert.Empty(t, empty.Deleted)
+
+	// обновление и удаление - изменённое событие и tombstone
+	t.Log("Инкрементальная синхронизация после изменений")
+	require.NoError(t, s.Update(&storage.Event{ID: id1, UserID: 1, Date: date, Title: "Первое (изм.)"}))
+	require.NoError(t, s.Delete(1, id2))
+
+	delta, err := s.Sync(1, full.SyncToken)
+	require.NoError(t, err)
+	require.Len(t, delta.Events, 1)
+	assert.Equal(t, "Первое (изм.)", delta.Events[0].Title)
+	assert.Equal(t, []int{id2}, delta.Deleted)
+
+	// чужой и испорченный токены отклоняются
+	_, err = s.Sync(2, delta.SyncToken)
+	assert.ErrorIs(t, err, storage.ErrInvalidSyncToken, "Токен другого пользователя не должен приниматься")
+	_, err = s.Sync(1, "мусор")
+	assert.ErrorIs(t, err, storage.ErrInvalidSyncToken)
+
+	// журнал вытеснил старые записи - требуется полная синхронизация
+	t.Log("Проверка устаревшего токена после уплотнения журнала")
+	for i := 0; i < 3; i++ {
+		_, err = s.Create(1, date, fmt.Sprintf("Новое %d", i), "")
+		require.NoError(t, err)
+	}
+	_, err = s.Sync(1, full.SyncToken)
+	assert.ErrorIs(t, err, storage.ErrSyncTokenExpired)
+
+	// свежий токен по-прежнему работает
+	fresh, err := s.Sync(1, delta.SyncToken)
+	require.NoError(t, err)
+	assert.Len(t, fresh.Events, 3)
+}
+
+// TestTrash проверяет корзину: удаление, восстановление с прежним ID и окончательное удаление
+func TestTrash(t *testing.T) {
+
+	s := storage.NewStorage()
+	s.TrashRetention = time.Hour
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	id, err := s.Create(1, date, "Важное", "")
+	require.NoError(t, err)
+
+	// удаление перемещает событие в корзину
+	require.NoError(t, s.Delete(1, id))
+	trash, err := s.Trash(1)
+	require.NoError(t, err)
+	require.Len(t, trash, 1, "Удалённое событие должно оказаться в корзине")
+	assert.Equal(t, id, trash[0].Event.ID)
+	assert.True(t, trash[0].ExpiresAt.After(trash[0].DeletedAt))
+
+	// восстановление возвращает событие с тем же ID
+	t.Log("Восстановление события из корзины")
+	restored, err := s.Restore(1, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, restored.ID, "ID должен сохраниться")
+	events, err := s.GetForDay(1, date)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	trash, err = s.Trash(1)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+
+	// повторное восстановление - ошибка
+	_, err = s.Restore(1, id)
+	assert.Error(t, err)
+
+	// окончательное удаление
+	t.Log("Окончательное удаление из корзины")
+	require.NoError(t, s.Delete(1, id))
+	require.NoError(t, s.Purge(1, id))
+	trash, err = s.Trash(1)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+	_, err = s.Restore(1, id)
+	assert.Error(t, err, "После purge восстановить событие нельзя")
+
+	// фоновая очистка удаляет только просроченное
+	t.Log("Очистка просроченных событий")
+	id2, err := s.Create(1, date, "Второе", "")
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(1, id2))
+
+	purged, err := s.PurgeExpired(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged, "Срок хранения ещё не истёк")
+
+	purged, err = s.PurgeExpired(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	trash, err = s.Trash(1)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+}
+
+// TestHistory проверяет историю версий события и откат
+func TestHistory(t *testing.T) {
+
+	s := storage.NewStorage()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	id, err := s.Create(1, date, "Черновик", "")
+	require.NoError(t, err)
+
+	// обновление от имени другого пользователя
+	require.NoError(t, s.UpdateBy(7, &storage.Event{ID: id, UserID: 1, Date: date, Title: "Итог", Content: "Текст"}))
+
+	history, err := s.History(1, id)
+	require.NoError(t, err)
+	require.Len(t, history, 2, "Должно быть две версии: создание и обновление")
+	assert.Equal(t, 1, history[0].ActorID, "Автор создания - владелец")
+	assert.Equal(t, 7, history[1].ActorID, "Автор обновления - тот, кто его внёс")
+
+	// diff содержит только изменённые поля
+	fields := make([]string, 0)
+	for _, change := range history[1].Diff {
+		fields = append(fields, change.Field)
+	}
+	assert.ElementsMatch(t, []string{"title", "content"}, fields)
+
+	// конкретная версия
+	revision, err := s.Revision(1, id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Черновик", revision.Event.Title)
+	_, err = s.Revision(1, id, 5)
+	assert.Error(t, err, "Несуществующая версия")
+	_, err = s.History(2, id)
+	assert.Error(t, err, "Чужую историю читать нельзя")
+
+	// откат создаёт новую версию
+	t.Log("Откат к первой версии")
+	reverted, err := s.Revert(1, id, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Черновик", reverted.Title)
+
+	events, err := s.GetForDay(1, date)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Черновик", events[0].Title)
+	assert.Empty(t, events[0].Content)
+
+	history, err = s.History(1, id)
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+}
+
+func TestAtomic(t *testing.T) {
+
+	s := storage.NewStorage()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	id, err := s.Create(1, date, "Исходное", "")
+	require.NoError(t, err)
+
+	notified := 0
+	s.Subscribe(func(storage.Change) { notified++ })
+
+	// ошибка в середине - откатывается всё
+	err = s.Atomic(func(tx storage.Tx) error {
+		if _, err := tx.Create(1, date, "Новое", ""); err != nil {
+			return err
+		}
+		if err := tx.UpdateBy(1, &storage.Event{ID: id, UserID: 1, Date: date, Title: "Изменено", Version: 1}); err != nil {
+			return err
+		}
+		return tx.DeleteIfMatch(1, 100, 0)
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	events, err := s.GetForDay(1, date)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "Созданное в транзакции событие должно пропасть")
+	assert.Equal(t, "Исходное", events[0].Title)
+	assert.Equal(t, 1, events[0].Version)
+	assert.Zero(t, notified, "Об отменённых изменениях подписчики не узнают")
+
+	history, err := s.History(1, id)
+	require.NoError(t, err)
+	assert.Len(t, history, 1, "История тоже откатывается")
+
+	// успешная транзакция
+	err = s.Atomic(func(tx storage.Tx) error {
+		newID, err := tx.Create(1, date, "Новое", "")
+		if err != nil {
+			return err
+		}
+		event, err := tx.Get(1, newID)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "Новое", event.Title, "Внутри транзакции видны её изменения")
+		return tx.DeleteIfMatch(1, id, 1)
+	})
+	require.NoError(t, err)
+
+	events, err = s.GetForDay(1, date)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Новое", events[0].Title)
+	assert.Equal(t, 2, notified, "Подписчики узнают о каждом изменении после фиксации")
+
+	// откат изменения и удаления одного события: оно возвращается из корзины в прежнем виде,
+	// журнал синхронизации и счётчик ID - как до транзакции
+	kept := events[0]
+	before, err := s.Sync(1, "")
+	require.NoError(t, err)
+	trashed, err := s.Trash(1)
+	require.NoError(t, err)
+	err = s.Atomic(func(tx storage.Tx) error {
+		if err := tx.UpdateBy(1, &storage.Event{ID: kept.ID, UserID: 1, Date: date, Title: "Изменено", Version: 1}); err != nil {
+			return err
+		}
+		if err := tx.DeleteIfMatch(1, kept.ID, 2); err != nil {
+			return err
+		}
+		if _, err := tx.Create(2, date, "Другой пользователь", ""); err != nil {
+			return err
+		}
+		return fmt.Errorf("отмена")
+	})
+	require.Error(t, err)
+
+	event, err := s.Get(1, kept.ID)
+	require.NoError(t, err)
+	assert.Equal(t, *kept, *event)
+	trash, err := s.Trash(1)
+	require.NoError(t, err)
+	assert.Len(t, trash, len(trashed), "Удалённое в транзакции событие не остаётся в корзине")
+	_, err = s.GetForDay(2, date)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "Пользователь, появившийся в транзакции, пропадает")
+	after, err := s.Sync(1, before.SyncToken)
+	require.NoError(t, err)
+	assert.Empty(t, after.Events)
+	assert.Equal(t, before.SyncToken, after.SyncToken)
+
+	nextID, err := s.Create(1, date, "После отката", "")
+	require.NoError(t, err)
+	assert.Equal(t, kept.ID+1, nextID, "Счётчик ID не должен сдвинуться")
+}
+
+// TestContextCancellation проверяет, что отменённый ctx прерывает ожидание хранилища
+func TestContextCancellation(t *testing.T) {
+
+	s := storage.NewStorage()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	// уже отменённый ctx - хранилище не трогаем
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.CreateContext(ctx, 1, date, "Встреча", "")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// хранилище занято - ждём до дедлайна
+	s.Mu.Lock()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = s.CreateContext(ctx, 1, date, "Встреча", "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	_, err = s.GetForDayContext(ctx, 1, date)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	s.Mu.Unlock()
+
+	// брошенное ожидание не оставляет блокировку за собой
+	id, err := s.CreateContext(context.Background(), 1, date, "Встреча", "")
+	require.NoError(t, err)
+	event, err := s.GetContext(context.Background(), 1, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Встреча", event.Title)
+
+	events, err := s.GetForDay(1, date)
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "Отменённые вызовы ничего не создали")
+
+	// транзакция тоже не начинается с отменённым ctx
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = s.AtomicContext(ctx, func(tx storage.Tx) error {
+		t.Fatal("транзакция не должна начаться")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// WithContext возвращает само хранилище, если оно умеет работать с ctx
+	assert.Equal(t, storage.ContextRepository(s), storage.WithContext(s))
+}
+
+// TestQuotas проверяет квоту событий пользователя и ограничения длины полей
+func TestQuotas(t *testing.T) {
+
+	s := storage.NewStorage()
+	s.MaxEventsPerUser = 2
+	s.MaxTitleLength = 5
+	s.MaxContentLength = 10
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	// длина считается в символах, а не байтах
+	id, err := s.Create(1, date, "Обед", "Суп и чай")
+	require.NoError(t, err)
+	_, err = s.Create(1, date, "Встреча", "")
+	assert.ErrorIs(t, err, storage.ErrTooLarge)
+	_, err = s.Create(1, date, "Обед", "Суп, чай и пирог")
+	assert.ErrorIs(t, err, storage.ErrTooLarge)
+
+	// обновление тоже проверяет длину
+	err = s.Update(&storage.Event{ID: id, UserID: 1, Date: date, Title: "Ужин с друзьями"})
+	assert.ErrorIs(t, err, storage.ErrTooLarge)
+
+	// квота у каждого пользователя своя
+	_, err = s.Create(1, date, "Сон", "")
+	require.NoError(t, err)
+	_, err = s.Create(1, date, "Спорт", "")
+	assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	_, err = s.Create(2, date, "Спорт", "")
+	assert.NoError(t, err)
+
+	// удаление освобождает место, восстановление из корзины снова его занимает
+	require.NoError(t, s.Delete(1, id))
+	_, err = s.Create(1, date, "Спорт", "")
+	require.NoError(t, err)
+	_, err = s.Restore(1, id)
+	assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	trash, err := s.Trash(1)
+	require.NoError(t, err)
+	assert.Len(t, trash, 1, "Событие остаётся в корзине")
+}
+
+// TestObserveOnce проверяет, что каждый вызов хранилища попадает в метрики один раз под своим именем
+func TestObserveOnce(t *testing.T) {
+
+	s := storage.NewStorage()
+	var methods []string
+	s.Observe(func(method string, _ time.Duration, _ error) {
+		methods = append(methods, method)
+	})
+
+	date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	id, err := s.Create(1, date, "Встреча", "")
+	require.NoError(t, err)
+	require.NoError(t, s.Update(&storage.Event{ID: id, UserID: 1, Date: date, Title: "Изменено"}))
+	require.NoError(t, s.UpdateBy(2, &storage.Event{ID: id, UserID: 1, Date: date, Title: "Ещё раз"}))
+	event, err := s.Revert(1, id, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Встреча", event.Title)
+	require.NoError(t, s.DeleteIfMatch(1, id, 4))
+
+	id, err = s.Create(1, date, "Обед", "")
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(1, id))
+
+	assert.Equal(t, []string{"Create", "Update", "UpdateBy", "Revert", "DeleteIfMatch", "Create", "Delete"}, methods)
+}
+
+// TestUnsubscribe проверяет, что отписанный подписчик больше не получает изменений
+func TestUnsubscribe(t *testing.T) {
+
+	s := storage.NewStorage()
+	date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	var first, second int
+	unsubscribe := s.Subscribe(func(storage.Change) { first++ })
+	s.Subscribe(func(storage.Change) { second++ })
+
+	_, err := s.Create(1, date, "Встреча", "")
+	require.NoError(t, err)
+	unsubscribe()
+	unsubscribe()
+	_, err = s.Create(1, date, "Обед", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, first)
+	assert.Equal(t, 2, second, "Остальные подписчики не затронуты")
+}
@@ -0,0 +1,396 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/IPampurin/calendar-server/pkg/grpcapi/calendarpb"
+	"github.com/IPampurin/calendar-server/pkg/server"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testCA - тестовый удостоверяющий центр
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	pem  []byte
+}
+
+// newTestCA создаёт самоподписанный CA
+func newTestCA(t *testing.T) *testCA {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "calendar test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testCA{cert: cert, key: key, pool: pool, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает сертификат сервера (localhost) или клиента с указанным CN, возвращает PEM сертификата и ключа
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, client bool) ([]byte, []byte) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}
+	if client {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeCert записывает PEM в файлы и сдвигает время изменения, чтобы перезагрузку было видно сразу
+func writeCert(t *testing.T, certFile, keyFile string, certPEM, keyPEM []byte, modTime time.Time) {
+
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+// TestTLSServer проверяет HTTPS с HTTP/2, перезагрузку сертификата без перезапуска и gRPC по TLS
+func TestTLSServer(t *testing.T) {
+
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	certPEM, keyPEM := ca.issue(t, "localhost", 10, false)
+	writeCert(t, certFile, keyFile, certPEM, keyPEM, time.Now().Add(-time.Minute))
+
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		Port:          "0",
+		GRPCPort:      "0",
+		WebhookOutbox: dir + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		TLS:           &server.TLSConfig{CertFile: certFile, KeyFile: keyFile, CheckInterval: time.Millisecond},
+	})
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+
+	get := func() *http.Response {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: ca.pool, ServerName: "localhost"},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + srv.Addr() + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+	assert.Equal(t, int64(10), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+
+	// новый сертификат подхватывается на следующем подключении
+	certPEM, keyPEM = ca.issue(t, "localhost", 11, false)
+	writeCert(t, certFile, keyFile, certPEM, keyPEM, time.Now())
+	time.Sleep(5 * time.Millisecond)
+	resp = get()
+	assert.Equal(t, int64(11), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+
+	// испорченный файл не ломает сервер - остаётся прежний сертификат
+	writeCert(t, certFile, keyFile, []byte("мусор"), keyPEM, time.Now().Add(time.Minute))
+	time.Sleep(5 * time.Millisecond)
+	resp = get()
+	assert.Equal(t, int64(11), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+
+	// gRPC на своём порту тоже по TLS
+	conn, err := grpc.NewClient(srv.GRPCAddr(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: ca.pool, ServerName: "localhost"})))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = calendarpb.NewCalendarServiceClient(conn).ListTrash(context.Background(), &calendarpb.ListTrashRequest{UserId: 1})
+	assert.NoError(t, err)
+}
+
+// TestMutualTLS проверяет обязательный сертификат клиента и сопоставление его пользователю
+func TestMutualTLS(t *testing.T) {
+
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.pem")
+	certPEM, keyPEM := ca.issue(t, "localhost", 10, false)
+	writeCert(t, certFile, keyFile, certPEM, keyPEM, time.Now())
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+
+	db := storage.NewStorage()
+	srv, err := server.New(db, server.Config{
+		Port:          "0",
+		GRPCPort:      "0",
+		WebhookOutbox: dir + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		TLS: &server.TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: caFile,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientUsers:  map[string]int{"alice": 7},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+
+	clientFor := func(commonName string) *http.Client {
+		config := &tls.Config{RootCAs: ca.pool, ServerName: "localhost"}
+		if commonName != "" {
+			certPEM, keyPEM := ca.issue(t, commonName, 20, true)
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			require.NoError(t, err)
+			config.Certificates = []tls.Certificate{cert}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	}
+
+	// без сертификата клиента соединение не устанавливается
+	_, err = clientFor("").Get("https://" + srv.Addr() + "/healthz")
+	assert.Error(t, err)
+
+	// alice по таблице - пользователь 7, X-Actor-ID от клиента не подделать
+	update := func(userID, eventID int) int {
+		req, err := http.NewRequest("POST", "https://"+srv.Addr()+"/update_event",
+			strings.NewReader(fmt.Sprintf(`{"id":%d,"user_id":%d,"date":"2026-01-16","title":"Встреча","version":1}`, eventID, userID)))
+		require.NoError(t, err)
+		req.Header.Set(api.HeaderActorID, "1")
+		resp, err := clientFor("alice").Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	own, err := db.Create(7, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, update(7, own))
+	history, err := db.History(7, own)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 7, history[1].ActorID)
+
+	// события другого пользователя сертификат alice не открывает
+	foreign, err := db.Create(3, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "Встреча", "")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, update(3, foreign))
+	history, err = db.History(3, foreign)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	// gRPC сопоставляет сертификат так же: пустой user_id - свой, чужой - PermissionDenied
+	grpcFor := func(commonName string) calendarpb.CalendarServiceClient {
+		certPEM, keyPEM := ca.issue(t, commonName, 30, true)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		conn, err := grpc.NewClient(srv.GRPCAddr(), grpc.WithTransportCredentials(credentials.NewTLS(
+			&tls.Config{RootCAs: ca.pool, ServerName: "localhost", Certificates: []tls.Certificate{cert}})))
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return calendarpb.NewCalendarServiceClient(conn)
+	}
+	alice := grpcFor("alice")
+	ctx := context.Background()
+
+	created, err := alice.CreateEvent(ctx, &calendarpb.CreateEventRequest{Date: "2026-01-17", Title: "По gRPC"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), created.GetUserId())
+
+	updated, err := alice.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{
+		Event:   &calendarpb.Event{Id: created.GetId(), UserId: 7, Date: "2026-01-18", Title: "По gRPC", Version: 1},
+		ActorId: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.GetVersion())
+	history, err = db.History(7, int(created.GetId()))
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 7, history[1].ActorID)
+
+	_, err = alice.GetEvent(ctx, &calendarpb.EventRef{UserId: 3, EventId: int64(foreign)})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = alice.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{
+		Event: &calendarpb.Event{Id: int64(foreign), UserId: 3, Date: "2026-01-18", Title: "Чужое", Version: 1},
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// поток изменений без user_id - только свои события
+	watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	watch, err := alice.WatchChanges(watchCtx, &calendarpb.WatchChangesRequest{})
+	require.NoError(t, err)
+	changes := make(chan *calendarpb.Change, 16)
+	go func() {
+		defer close(changes)
+		for {
+			change, err := watch.Recv()
+			if err != nil {
+				return
+			}
+			changes <- change
+		}
+	}()
+	// поток регистрируется на сервере не мгновенно - создаём события, пока одно не придёт
+	var change *calendarpb.Change
+	for attempt := 0; attempt < 100 && change == nil; attempt++ {
+		_, err = db.Create(3, time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC), "Чужое", "")
+		require.NoError(t, err)
+		_, err = db.Create(7, time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC), "Своё", "")
+		require.NoError(t, err)
+		select {
+		case change = <-changes:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, change, "Изменение не пришло в поток")
+	assert.Equal(t, int64(7), change.GetUserId())
+
+	_, err = grpcFor("mallory").ListTrash(ctx, &calendarpb.ListTrashRequest{UserId: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// CN-число - ID пользователя, незнакомый CN - 403
+	resp, err := clientFor("42").Get("https://" + srv.Addr() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = clientFor("mallory").Get("https://" + srv.Addr() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+// TestH2C проверяет HTTP/2 без TLS
+func TestH2C(t *testing.T) {
+
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		Port:          "0",
+		WebhookOutbox: t.TempDir() + "/webhooks.json",
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		H2C:           true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	resp, err := client.Get("http://" + srv.Addr() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+
+	// HTTP/1.1 работает как раньше
+	resp, err = http.Get("http://" + srv.Addr() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+}
+
+// TestClientCertMiddleware проверяет X-Actor-ID без сертификата и запрет запросов к чужим событиям по сертификату
+func TestClientCertMiddleware(t *testing.T) {
+
+	var actor, body string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = r.Header.Get(api.HeaderActorID)
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	})
+
+	// без проверки клиентов X-Actor-ID проходит как есть, с проверкой - только из сертификата
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(api.HeaderActorID, "5")
+	server.ClientCertMiddleware(server.TLSConfig{}, 0)(next).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "5", actor)
+
+	handler := server.ClientCertMiddleware(server.TLSConfig{ClientAuth: tls.VerifyClientCertIfGiven}, 0)(next)
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(api.HeaderActorID, "5")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, actor)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "7"}}
+	request := func(method, target, payload string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(payload))
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		req.Header.Set(api.HeaderActorID, "5")
+		rec := httptest.NewRecorder()
+		actor, body = "", ""
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// свой пользователь - запрос проходит с автором из сертификата и нетронутым телом
+	payload := `{"user_id":7,"date":"2026-01-15","title":"Встреча"}`
+	assert.Equal(t, http.StatusOK, request("POST", "/create_event", payload))
+	assert.Equal(t, "7", actor)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, http.StatusOK, request("GET", "/v2/users/7/events", ""))
+	assert.Equal(t, http.StatusOK, request("GET", "/events_for_day?user_id=7&date=2026-01-15", ""))
+
+	// чужой пользователь в пути, параметре, теле или операции пакета - 403
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v2/users/8/events", ""))
+	assert.Equal(t, http.StatusForbidden, request("GET", "/events_for_day?user_id=8&date=2026-01-15", ""))
+	assert.Equal(t, http.StatusForbidden, request("POST", "/create_event", `{"user_id":8,"date":"2026-01-15","title":"Встреча"}`))
+	assert.Equal(t, http.StatusForbidden, request("POST", "/batch", `{"operations":[
+		{"op":"create","user_id":7,"date":"2026-01-15","title":"Своё"},
+		{"op":"delete","user_id":8,"event_id":1}
+	]}`))
+	assert.Empty(t, actor, "Запрос к чужим событиям не должен дойти до хэндлера")
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/client"
+	"github.com/IPampurin/calendar-server/pkg/server"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/IPampurin/calendar-server/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useSpanRecorder подменяет глобальный TracerProvider на записывающий span в память
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+// TestTracingHTTP проверяет span запроса, дочерние span хранилища, traceparent и trace_id в логе
+func TestTracingHTTP(t *testing.T) {
+
+	recorder := useSpanRecorder(t)
+
+	var logs bytes.Buffer
+	srv, err := server.New(storage.NewStorage(), server.Config{
+		WebhookOutbox: t.TempDir() + "/webhooks.json",
+		Logger:        slog.New(slog.NewJSONHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("POST", "/create_event", bytes.NewBufferString(`{"user_id":1,"date":"2026-01-15","title":"Встреча"}`))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set("Prefer", "return=representation") // после Create ещё и Get
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	// span запроса продолжает трассировку клиента и назван по маршруту
+	request, ok := spans["POST /create_event"]
+	require.True(t, ok, "нет span запроса: %v", spans)
+	assert.Equal(t, trace.SpanKindServer, request.SpanKind())
+	assert.Equal(t, traceID, request.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent().SpanID().String())
+
+	// вызовы хранилища - дочерние span
+	for _, name := range []string{"storage.Create", "storage.Get"} {
+		span, ok := spans[name]
+		require.True(t, ok, "нет span %s", name)
+		assert.Equal(t, request.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+	}
+
+	assert.Contains(t, logs.String(), `"trace_id":"`+traceID+`"`)
+}
+
+// TestTracingStorageError проверяет, что ошибка хранилища отмечается в span
+func TestTracingStorageError(t *testing.T) {
+
+	recorder := useSpanRecorder(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	repo := tracing.Repository(storage.WithContext(storage.NewStorage()))
+	_, err := repo.GetContext(ctx, 1, 42)
+	require.Error(t, err)
+	parent.End()
+
+	var found bool
+	for _, span := range recorder.Ended() {
+		if span.Name() == "storage.Get" {
+			found = true
+			assert.Equal(t, "Error", span.Status().Code.String())
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		}
+	}
+	assert.True(t, found)
+}
+
+// TestTracingClientPropagation проверяет, что клиент передаёт traceparent
+func TestTracingClientPropagation(t *testing.T) {
+
+	useSpanRecorder(t)
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	c, err := client.New(ts.URL)
+	require.NoError(t, err)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "calctl")
+	defer span.End()
+	_, err = c.EventsForDay(ctx, 1, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+}
+
+// TestTracingSetup проверяет выбор экспортёра
+func TestTracingSetup(t *testing.T) {
+
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var out bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "stdout", Writer: &out, SampleRatio: 1})
+	require.NoError(t, err)
+
+	_, span := tracing.Tracer().Start(context.Background(), "проверка")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, out.String(), "проверка")
+	assert.Contains(t, out.String(), "calendar-server")
+
+	// доля 0 - ни одного span, отрицательная - все
+	for ratio, recorded := range map[float64]bool{0: false, -1: true} {
+		out.Reset()
+		shutdown, err = tracing.Setup(context.Background(), tracing.Config{Exporter: "stdout", Writer: &out, SampleRatio: ratio})
+		require.NoError(t, err)
+		_, span = tracing.Tracer().Start(context.Background(), "выборка")
+		assert.Equal(t, recorded, span.IsRecording(), "sample_ratio %v", ratio)
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+		assert.Equal(t, recorded, strings.Contains(out.String(), "выборка"), "sample_ratio %v", ratio)
+	}
+
+	shutdown, err = tracing.Setup(context.Background(), tracing.Config{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = tracing.Setup(context.Background(), tracing.Config{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,322 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IPampurin/calendar-server/pkg/api"
+	"github.com/IPampurin/calendar-server/pkg/storage"
+	"github.com/IPampurin/calendar-server/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWebhookDelivery проверяет доставку изменений с подписью и повтором после ошибки
+func TestWebhookDelivery(t *testing.T) {
+
+	// приёмник: первый запрос отклоняет, дальше принимает и запоминает тела
+	var mu sync.Mutex
+	var calls int
+	var bodies [][]byte
+	var signatures []string
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		bodies = append(bodies, body)
+		signatures = append(signatures, r.Header.Get(webhook.HeaderSignature))
+	}))
+	defer receiver.Close()
+
+	outbox := filepath.Join(t.TempDir(), "webhooks.json")
+	d, err := webhook.New(webhook.Config{
+		Path:         outbox,
+		BaseBackoff:  10 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	sub, err := d.AddSubscription(webhook.Subscription{
+		URL:        receiver.URL,
+		Secret:     "s3cr3t",
+		EventTypes: []storage.ChangeType{storage.ChangeCreated},
+		UserID:     1,
+	})
+	require.NoError(t, err)
+
+	s := storage.NewStorage()
+	s.Subscribe(d.Notify)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.Start(ctx)
+	defer func() {
+		cancel()
+		d.Wait()
+	}()
+
+	// создание у нужного пользователя - доставляется, у чужого и обновление - нет
+	id, err := s.Create(1, time.Now(), "Встреча", "")
+	require.NoError(t, err)
+	_, err = s.Create(2, time.Now(), "Чужое", "")
+	require.NoError(t, err)
+	require.NoError(t, s.Update(&storage.Event{ID: id, UserID: 1, Title: "Другое"}))
+
+	require.Eventually(t, func() bool {
+		deliveries, err := d.Deliveries(sub.ID)
+		return err == nil && len(deliveries) == 1 && deliveries[0].Status == webhook.StatusDelivered
+	}, 2*time.Second, 10*time.Millisecond, "доставка должна завершиться после повтора")
+
+	deliveries, err := d.Deliveries(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deliveries[0].Attempts, "первая попытка неудачна, вторая успешна")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, bodies, 1)
+	assert.True(t, webhook.Verify("s3cr3t", bodies[0], signatures[0]), "подпись должна сходиться")
+
+	var payload webhook.Payload
+	require.NoError(t, json.Unmarshal(bodies[0], &payload))
+	assert.Equal(t, string(storage.ChangeCreated), payload.Type)
+	assert.Equal(t, "Встреча", payload.Change.Event.Title)
+
+	// подписка и история переживают перезапуск
+	reloaded, err := webhook.New(webhook.Config{Path: outbox})
+	require.NoError(t, err)
+	require.Len(t, reloaded.Subscriptions(1), 1)
+	assert.Equal(t, "s3cr3t", reloaded.Subscriptions(1)[0].Secret, "Секрет нужен для подписи после перезапуска")
+}
+
+// TestWebhookAPI проверяет управление подписками через HTTP
+func TestWebhookAPI(t *testing.T) {
+
+	d, err := webhook.New(webhook.Config{})
+	require.NoError(t, err)
+	apiMock := api.NewAPI(newMockStorage(), api.WithWebhooks(d))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /create_webhook", apiMock.CreateWebhookHandler)
+	mux.HandleFunc("POST /delete_webhook", apiMock.DeleteWebhookHandler)
+	mux.HandleFunc("GET /webhooks", apiMock.GetWebhooksHandler)
+	mux.HandleFunc("GET /webhook_deliveries", apiMock.GetWebhookDeliveriesHandler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := server.Client()
+
+	// некорректный адрес
+	resp, err := client.Post(server.URL+"/create_webhook", "application/json", bytes.NewBufferString(`{"url":"ftp://x"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// корректная подписка - секрет генерируется сервером
+	resp, err = client.Post(server.URL+"/create_webhook", "application/json",
+		bytes.NewBufferString(`{"url":"http://localhost/hook","event_types":["event.deleted"],"user_id":5}`))
+	require.NoError(t, err)
+	var answer struct {
+		Result webhook.CreatedSubscription `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&answer))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.NotEmpty(t, answer.Result.Secret)
+
+	// без user_id список не отдаётся: иначе видны подписки всех пользователей
+	for _, query := range []string{"", "?user_id=", "?user_id=0", "?user_id=abc"} {
+		resp, err = client.Get(server.URL + "/webhooks" + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Запрос %q", query)
+	}
+
+	// в списке подписок секрета нет
+	resp, err = client.Get(server.URL + "/webhooks?user_id=5")
+	require.NoError(t, err)
+	list, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(list), "http://localhost/hook")
+	assert.NotContains(t, string(list), "secret")
+	assert.NotContains(t, string(list), answer.Result.Secret)
+
+	resp, err = client.Get(server.URL + "/webhook_deliveries?id=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Post(server.URL+"/delete_webhook", "application/json", bytes.NewBufferString(`{"id":1}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Get(server.URL + "/webhook_deliveries?id=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestWebhookOutboxFlush проверяет, что новая доставка попадает на диск до возврата из Notify
+// (падение процесса сразу после изменения события её не теряет), а фоновая доставка
+// сбрасывает результаты попыток и при остановке
+func TestWebhookOutboxFlush(t *testing.T) {
+
+	outbox := filepath.Join(t.TempDir(), "webhooks.json")
+	d, err := webhook.New(webhook.Config{Path: outbox, PollInterval: time.Hour})
+	require.NoError(t, err)
+	_, err = d.AddSubscription(webhook.Subscription{URL: "http://127.0.0.1:1/hook"})
+	require.NoError(t, err)
+
+	saved := func() []*webhook.Delivery {
+		reloaded, err := webhook.New(webhook.Config{Path: outbox})
+		require.NoError(t, err)
+		deliveries, err := reloaded.Deliveries(1)
+		require.NoError(t, err)
+		return deliveries
+	}
+
+	s := storage.NewStorage()
+	s.Subscribe(d.Notify)
+	for i := range 3 {
+		_, err := s.Create(1, time.Now(), fmt.Sprintf("Встреча %d", i), "")
+		require.NoError(t, err)
+	}
+	assert.Len(t, saved(), 3, "Outbox сохранён без Flush и фоновой доставки")
+
+	// результаты попыток остановка сбрасывает на диск
+	ctx, cancel := context.WithCancel(context.Background())
+	d.Start(ctx)
+	_, err = s.Create(1, time.Now(), "Ещё одна", "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		deliveries, err := d.Deliveries(1)
+		require.NoError(t, err)
+		for _, delivery := range deliveries {
+			if delivery.Attempts == 0 {
+				return false
+			}
+		}
+		return len(deliveries) == 4
+	}, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	d.Wait()
+
+	deliveries := saved()
+	require.Len(t, deliveries, 4)
+	for _, delivery := range deliveries {
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.NotEmpty(t, delivery.LastError)
+	}
+}
+
+// TestWebhookSlowReceiver проверяет, что медленный приёмник не задерживает доставку другим подписчикам,
+// а одному подписчику доставки приходят по порядку
+func TestWebhookSlowReceiver(t *testing.T) {
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	var mu sync.Mutex
+	received := make([]string, 0)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get(webhook.HeaderDelivery))
+	}))
+	defer fast.Close()
+
+	d, err := webhook.New(webhook.Config{PollInterval: time.Hour})
+	require.NoError(t, err)
+	_, err = d.AddSubscription(webhook.Subscription{URL: slow.URL})
+	require.NoError(t, err)
+	fastSub, err := d.AddSubscription(webhook.Subscription{URL: fast.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer d.Wait()
+	defer cancel()
+	d.Start(ctx)
+
+	for range 3 {
+		d.Notify(storage.Change{Type: storage.ChangeCreated, UserID: 1})
+	}
+
+	// медленный приёмник всё ещё держит первую доставку, быстрый получил все три по порядку
+	require.Eventually(t, func() bool {
+		deliveries, err := d.Deliveries(fastSub.ID)
+		require.NoError(t, err)
+		for _, delivery := range deliveries {
+			if delivery.Status != webhook.StatusDelivered {
+				return false
+			}
+		}
+		return len(deliveries) == 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"2", "4", "6"}, received)
+}
+
+// TestWebhookShutdownKeepsAttempts проверяет, что прерванная остановкой отправка не считается попыткой
+func TestWebhookShutdownKeepsAttempts(t *testing.T) {
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		<-release // не отвечаем, пока диспетчер не остановится
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	outbox := filepath.Join(t.TempDir(), "webhooks.json")
+	d, err := webhook.New(webhook.Config{Path: outbox, PollInterval: 5 * time.Millisecond})
+	require.NoError(t, err)
+	sub, err := d.AddSubscription(webhook.Subscription{URL: receiver.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.Start(ctx)
+	d.Notify(storage.Change{Type: storage.ChangeCreated, UserID: 1})
+	<-started
+	cancel()
+	d.Wait()
+
+	deliveries, err := d.Deliveries(sub.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, webhook.StatusPending, deliveries[0].Status)
+	assert.Equal(t, 0, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].LastError)
+
+	// после перезапуска доставка всё ещё ждёт отправки
+	reloaded, err := webhook.New(webhook.Config{Path: outbox})
+	require.NoError(t, err)
+	deliveries, err = reloaded.Deliveries(sub.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, webhook.StatusPending, deliveries[0].Status)
+}